@@ -0,0 +1,112 @@
+package hawkflow
+
+import (
+	"fmt"
+	"time"
+)
+
+// Run is a completed run submitted with Backfill, e.g. from the history of
+// another scheduler.
+type Run struct {
+	Process string `json:"process"`
+	Meta    string `json:"meta,omitempty"`
+	UID     string `json:"uid,omitempty"`
+	// ParentUID nests the run in the run with this uid, like a child Timer.
+	ParentUID string    `json:"parent_uid,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	// Exception marks the run as failed with this message.
+	Exception string `json:"exception,omitempty"`
+	// Metrics are reported at the end of the run.
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+}
+
+type pathRequest struct {
+	path string
+	r    *request
+}
+
+// Backfill submits a completed run: a Start at run.Start, an Exception and
+// Metrics if set, and an End at run.End carrying the run's duration, marked
+// as failed if the run has an exception. The whole run is validated before
+// anything is sent. Backfilled events are never sampled and wait for the
+// rate limit instead of being dropped. Their idempotency keys are derived
+// from the run, so backfilling a run twice does not duplicate it.
+func (hfc *client) Backfill(run Run) error {
+	base := request{Process: run.Process, Meta: run.Meta, UID: run.UID, ParentUID: run.ParentUID}
+	if hfc.sanitize {
+		hfc.sanitizeRequest(&base)
+	}
+	items := hfc.repairItems(run.Metrics)
+
+	if err := hfc.validateRun(&base, run, items); err != nil {
+		return err
+	}
+
+	start := base
+	start.Timestamp = formatTimestamp(run.Start)
+	events := []pathRequest{{"start", &start}}
+
+	if run.Exception != "" {
+		exception := base
+		exception.ExceptionMessage = run.Exception
+		exception.Timestamp = formatTimestamp(run.End)
+		events = append(events, pathRequest{"exception", &exception})
+	}
+	if items != nil {
+		metrics := base
+		metrics.Items = items
+		metrics.Timestamp = formatTimestamp(run.End)
+		events = append(events, pathRequest{"metrics", &metrics})
+	}
+
+	end := base
+	end.Timestamp = formatTimestamp(run.End)
+	end.DurationMs = durationMs(run.End.Sub(run.Start))
+	end.Failed = run.Exception != ""
+	events = append(events, pathRequest{"end", &end})
+
+	if hfc.debug {
+		hfc.logf("Backfill: %s %s", base.Process, start.Timestamp)
+	}
+	for _, e := range events {
+		e.r.key = runIdempotencyKey(&base, run.Start, e.path)
+		if l := hfc.limiter; l != nil {
+			if wait, _ := l.reserve(e.r.Process, true); wait > 0 {
+				l.sleep(wait)
+			}
+		}
+		if err := hfc.sendWithRetry(e.r, e.path, hfc.maxRetries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hfc *client) validateRun(base *request, run Run, items map[string]float64) error {
+	l := &hfc.limits
+	vs := l.appendProcess(nil, base.Process)
+	vs = l.appendMeta(vs, base.Meta)
+	vs = l.appendUID(vs, base.UID)
+	if base.ParentUID != "" {
+		vs = appendField(vs, "parent_uid", base.ParentUID, l.UID, "", "ParentUID parameter")
+	}
+	if run.Exception != "" {
+		vs = l.appendException(vs, run.Exception)
+	}
+	if items != nil {
+		vs = l.appendItems(vs, items)
+	}
+
+	switch {
+	case run.Start.IsZero():
+		vs = append(vs, Violation{Field: "start", Message: "No run start time set."})
+	case run.End.IsZero():
+		vs = append(vs, Violation{Field: "end", Message: "No run end time set."})
+	case run.End.Before(run.Start):
+		vs = append(vs, Violation{Field: "end", Message: fmt.Sprintf("Run end %s is before its start %s.",
+			formatTimestamp(run.End), formatTimestamp(run.Start))})
+	}
+
+	return newValidationError(vs, hfc.validateAll)
+}
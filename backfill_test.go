@@ -0,0 +1,186 @@
+package hawkflow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackfill(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec), OptionSampleRate(0))
+
+	start := time.Date(2023, 5, 1, 2, 0, 0, 0, time.UTC)
+	err := hfc.Backfill(Run{
+		Process:   "nightly_export",
+		UID:       "run_1",
+		Start:     start,
+		End:       start.Add(90 * time.Second),
+		Exception: "disk full",
+		Metrics:   map[string]float64{"rows": 12},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []request{
+		{Process: "nightly_export", UID: "run_1", Timestamp: "2023-05-01T02:00:00Z"},
+		{Process: "nightly_export", UID: "run_1", ExceptionMessage: "disk full", Timestamp: "2023-05-01T02:01:30Z"},
+		{Process: "nightly_export", UID: "run_1", Items: map[string]float64{"rows": 12}, Timestamp: "2023-05-01T02:01:30Z"},
+		{Process: "nightly_export", UID: "run_1", Timestamp: "2023-05-01T02:01:30Z", DurationMs: 90000, Failed: true},
+	}
+	if len(rec.requests) != len(expected) {
+		t.Fatalf("%v expected, got %v", len(expected), len(rec.requests))
+	}
+	for i, r := range rec.requests {
+		e := expected[i]
+		if r.UID != e.UID || r.ExceptionMessage != e.ExceptionMessage || r.Timestamp != e.Timestamp ||
+			r.DurationMs != e.DurationMs || r.Failed != e.Failed || len(r.Items) != len(e.Items) || r.SampleRate != 0 {
+			t.Errorf("%+v expected, got %+v", e, r)
+		}
+	}
+}
+
+func TestBackfillValidation(t *testing.T) {
+	start := time.Date(2023, 5, 1, 2, 0, 0, 0, time.UTC)
+	testCases := map[string]struct {
+		run   Run
+		error string
+	}{
+		"Missing start": {
+			run:   Run{Process: "test_process", End: start},
+			error: "No run start time set.",
+		},
+		"Missing end": {
+			run:   Run{Process: "test_process", Start: start},
+			error: "No run end time set.",
+		},
+		"End before start": {
+			run:   Run{Process: "test_process", Start: start, End: start.Add(-time.Second)},
+			error: "Run end 2023-05-01T01:59:59Z is before its start 2023-05-01T02:00:00Z.",
+		},
+		"Invalid process": {
+			run:   Run{Process: "invalid process ❌", Start: start, End: start},
+			error: "Process parameter contains unsupported characters.",
+		},
+		"Invalid parent uid": {
+			run:   Run{Process: "test_process", ParentUID: "invalid uid ❌", Start: start, End: start},
+			error: "ParentUID parameter contains unsupported characters.",
+		},
+		"Invalid metrics": {
+			run:   Run{Process: "test_process", Start: start, End: start, Metrics: map[string]float64{}},
+			error: "No items set.",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rec := &sampleRecorder{}
+			hfc := New("api_key", OptionHTTPClient(rec))
+			err := hfc.Backfill(testCase.run)
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) || verr.Violations[0].Message != testCase.error {
+				t.Errorf("%v expected, got %v", testCase.error, err)
+			}
+			if len(rec.requests) != 0 {
+				t.Errorf("nothing sent expected, got %v", rec.requests)
+			}
+		})
+	}
+}
+
+func TestImportNestedRuns(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec))
+	input := `process,uid,parent_uid,start,end
+nightly_export,run_1,,2023-05-01T02:00:00Z,2023-05-01T02:01:30Z
+load,run_1_load,run_1,2023-05-01T02:00:10Z,2023-05-01T02:01:00Z
+`
+	result, err := hfc.Import(strings.NewReader(input), ImportCSV)
+	if err != nil || result.Imported != 2 {
+		t.Fatalf("%v runs expected, got %+v, %v", 2, result, err)
+	}
+
+	for _, r := range rec.requests[2:] {
+		if r.Process != "load" || r.ParentUID != "run_1" {
+			t.Errorf("nested run expected, got %+v", r)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	testCases := map[string]struct {
+		format         ImportFormat
+		input          string
+		expectedRuns   int
+		expectedErrors []string
+		error          bool
+	}{
+		"CSV": {
+			format: ImportCSV,
+			input: `process,uid,start,end,exception,metrics.rows,metrics.Size
+nightly_export,run_1,2023-05-01T02:00:00Z,2023-05-01T02:01:30Z,,12,
+nightly_export,run_2,2023-05-02T02:00:00Z,2023-05-02T02:03:00Z,disk full,,1.5
+invalid process ❌,run_3,2023-05-03T02:00:00Z,2023-05-03T02:01:00Z,,,
+nightly_export,run_4,yesterday,2023-05-04T02:01:00Z,,,
+nightly_export,run_5,2023-05-05T02:00:00Z,2023-05-05T02:01:00Z,,many,
+nightly_export,run_6
+`,
+			expectedRuns: 2,
+			expectedErrors: []string{
+				"row 4: Process parameter contains unsupported characters. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+				`row 5: Invalid start time "yesterday", expected RFC 3339. Please see documentation at https://docs.hawkflow.ai/integration/index.html`,
+				`row 6: Invalid value "many" for metrics.rows. Please see documentation at https://docs.hawkflow.ai/integration/index.html`,
+				"row 7: wrong number of fields",
+			},
+		},
+		"CSV unknown column": {
+			format: ImportCSV,
+			input:  "process,start,end,duration\n",
+			error:  true,
+		},
+		"CSV missing column": {
+			format: ImportCSV,
+			input:  "process,start\n",
+			error:  true,
+		},
+		"JSONL": {
+			format: ImportJSONL,
+			input: `{"process":"nightly_export","uid":"run_1","start":"2023-05-01T02:00:00Z","end":"2023-05-01T02:01:30Z","metrics":{"rows":12}}
+
+{"process":"nightly_export","uid":"run_2","start":"2023-05-02T02:00:00Z","end":"2023-05-01T02:00:00Z"}
+{"process":"nightly_export","duration":3}
+{"process":`,
+			expectedRuns: 1,
+			expectedErrors: []string{
+				"row 3: Run end 2023-05-01T02:00:00Z is before its start 2023-05-02T02:00:00Z. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+				`row 4: json: unknown field "duration"`,
+				"row 5: unexpected EOF",
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			hfc := New("api_key", OptionHTTPClient(&sampleRecorder{}))
+			result, err := hfc.Import(strings.NewReader(testCase.input), testCase.format)
+
+			if (err != nil) != testCase.error {
+				t.Fatalf("error %v expected, got %v", testCase.error, err)
+			}
+			if result.Imported != testCase.expectedRuns {
+				t.Errorf("%v expected, got %v", testCase.expectedRuns, result.Imported)
+			}
+			if len(result.Errors) != len(testCase.expectedErrors) {
+				t.Fatalf("%v expected, got %v", testCase.expectedErrors, result.Errors)
+			}
+			for i, e := range result.Errors {
+				if e.Error() != testCase.expectedErrors[i] {
+					t.Errorf("%v expected, got %v", testCase.expectedErrors[i], e.Error())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package hawkflow
+
+import (
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the API while the circuit
+// breaker is open.
+var ErrCircuitOpen = createError("Circuit breaker is open, event not sent.")
+
+// CircuitState is the state of the circuit breaker set up by
+// OptionCircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed sends requests normally.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every request with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe request through after the cooldown.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// OptionCircuitBreaker opens the circuit after threshold consecutive failed
+// requests, i.e. connection errors, timeouts, 429 and 5xx responses. While
+// open, events fail immediately with ErrCircuitOpen instead of spending
+// retries and timeouts. After cooldown one probe request is let through; it
+// closes the circuit on success and reopens it on failure.
+func OptionCircuitBreaker(threshold int, cooldown time.Duration) func(*client) {
+	return func(hfc *client) {
+		if threshold <= 0 {
+			hfc.breaker = nil
+			return
+		}
+		if hfc.breaker == nil {
+			hfc.breaker = &breaker{now: time.Now}
+		}
+		hfc.breaker.threshold = threshold
+		hfc.breaker.cooldown = cooldown
+	}
+}
+
+// OptionCircuitStateChange calls f on every circuit breaker state change. It
+// is called synchronously from the sending goroutine and must not block.
+func OptionCircuitStateChange(f func(from, to CircuitState)) func(*client) {
+	return func(hfc *client) { hfc.onCircuitChange = f }
+}
+
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+	opens    uint64
+	rejected uint64
+	// generation counts state changes. Requests carry the generation they
+	// were let through in, so results of requests that started before the
+	// last change, e.g. slow requests finishing after the circuit opened,
+	// are not mistaken for the probe.
+	generation uint64
+}
+
+// setState changes the state and starts a new generation.
+func (b *breaker) setState(s CircuitState) {
+	if b.state != s {
+		b.state = s
+		b.generation++
+	}
+}
+
+// allow reports whether a request may be sent and the generation to pass to
+// done. In the half-open state only one probe is in flight at a time.
+func (b *breaker) allow() (uint64, bool, CircuitState, CircuitState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	from := b.state
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			b.rejected++
+			return b.generation, false, from, from
+		}
+		b.setState(CircuitHalfOpen)
+		b.probing = true
+	case CircuitHalfOpen:
+		if b.probing {
+			b.rejected++
+			return b.generation, false, from, from
+		}
+		b.probing = true
+	}
+	return b.generation, true, from, b.state
+}
+
+// done records the outcome of a request let through in generation. Outcomes
+// from earlier generations are ignored.
+func (b *breaker) done(generation uint64, failed bool) (CircuitState, CircuitState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	from := b.state
+	if generation != b.generation {
+		return from, from
+	}
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		b.setState(CircuitClosed)
+		return from, b.state
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		if b.state != CircuitOpen {
+			b.opens++
+		}
+		b.setState(CircuitOpen)
+		b.openedAt = b.now()
+	}
+	return from, b.state
+}
+
+// circuitAllow returns ErrCircuitOpen if the request may not be sent, or the
+// generation to pass to circuitDone.
+func (hfc *client) circuitAllow() (uint64, error) {
+	if hfc.breaker == nil {
+		return 0, nil
+	}
+	generation, ok, from, to := hfc.breaker.allow()
+	hfc.circuitChanged(from, to)
+	if !ok {
+		return 0, ErrCircuitOpen
+	}
+	return generation, nil
+}
+
+// circuitDone records the outcome of a request let through by circuitAllow.
+func (hfc *client) circuitDone(generation uint64, status int, err error) {
+	if hfc.breaker == nil {
+		return
+	}
+	failed := err != nil || status == 429 || status >= 500
+	hfc.circuitChanged(hfc.breaker.done(generation, failed))
+}
+
+func (hfc *client) circuitChanged(from, to CircuitState) {
+	if from == to {
+		return
+	}
+	if hfc.debug {
+		hfc.logf("Circuit breaker %s -> %s", from, to)
+	}
+	if hfc.onCircuitChange != nil {
+		hfc.onCircuitChange(from, to)
+	}
+}
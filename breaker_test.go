@@ -0,0 +1,140 @@
+package hawkflow
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	mock := &ClientMock{returnStatusCode: 503}
+
+	var changes []string
+	hfc := New("api_key",
+		OptionHTTPClient(mock),
+		OptionMaxRetries(3),
+		OptionCircuitBreaker(5, time.Minute),
+		OptionCircuitStateChange(func(from, to CircuitState) {
+			changes = append(changes, from.String()+" -> "+to.String())
+		}),
+	)
+	hfc.breaker.now = func() time.Time { return now }
+
+	// Five failed attempts open the circuit during the second event.
+	_ = hfc.Start("test_process", "", "")
+	err := hfc.Start("test_process", "", "")
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("%v expected, got %v", ErrCircuitOpen, err)
+	}
+	if mock.count != 5 {
+		t.Errorf("%v attempts expected, got %v", 5, mock.count)
+	}
+
+	// Open circuits fail fast.
+	if err := hfc.End("test_process", "", ""); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("%v expected, got %v", ErrCircuitOpen, err)
+	}
+	if mock.count != 5 {
+		t.Errorf("%v attempts expected, got %v", 5, mock.count)
+	}
+
+	// A failed probe after the cooldown reopens it.
+	now = now.Add(time.Minute)
+	_ = hfc.End("test_process", "", "")
+	if mock.count != 6 {
+		t.Errorf("%v attempts expected, got %v", 6, mock.count)
+	}
+
+	// A successful probe closes it.
+	now = now.Add(time.Minute)
+	mock.returnStatusCode = 201
+	if err := hfc.End("test_process", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"closed -> open", "open -> half-open", "half-open -> open", "open -> half-open", "half-open -> closed"}
+	if len(changes) != len(expected) {
+		t.Fatalf("%v expected, got %v", expected, changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("%v expected, got %v", expected, changes)
+		}
+	}
+
+	stats := hfc.Stats()
+	if stats.Circuit != CircuitClosed || stats.ConsecutiveFailures != 0 || stats.CircuitOpened != 2 || stats.CircuitRejected != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := &breaker{threshold: 1, cooldown: time.Second, now: func() time.Time { return now }}
+	b.done(0, true)
+
+	now = now.Add(time.Second)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, _, _ := b.allow(); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 1 {
+		t.Errorf("%v expected, got %v", 1, allowed)
+	}
+}
+
+func TestCircuitBreakerIgnoresLateResults(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := &breaker{threshold: 1, cooldown: time.Second, now: func() time.Time { return now }}
+
+	// Two requests start while closed; the first failure opens the circuit.
+	slow, _, _, _ := b.allow()
+	failing, _, _, _ := b.allow()
+	b.done(failing, true)
+
+	// A late success must not close the circuit without a probe.
+	b.done(slow, false)
+	if b.state != CircuitOpen {
+		t.Fatalf("%v expected, got %v", CircuitOpen, b.state)
+	}
+
+	now = now.Add(time.Second)
+	probe, ok, _, _ := b.allow()
+	if !ok {
+		t.Fatal("probe expected")
+	}
+	// A late failure must not end the probe and let a second one through.
+	b.done(slow, true)
+	if _, ok, _, _ := b.allow(); ok {
+		t.Errorf("single probe expected")
+	}
+
+	b.done(probe, false)
+	if b.state != CircuitClosed {
+		t.Errorf("%v expected, got %v", CircuitClosed, b.state)
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	hfc := New("api_key", OptionHTTPClient(&ClientMock{returnStatusCode: 401}), OptionCircuitBreaker(1, time.Minute))
+
+	_ = hfc.Start("test_process", "", "")
+	_ = hfc.Start("test_process", "", "")
+	if s := hfc.Stats().Circuit; s != CircuitClosed {
+		t.Errorf("%v expected, got %v", CircuitClosed, s)
+	}
+}
@@ -0,0 +1,103 @@
+package hawkflow
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// _MAX_POOLED_BUFFER keeps unusually large buffers, e.g. from a 15000
+// character exception, from being pooled.
+const _MAX_POOLED_BUFFER = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := new(buffer)
+		b.getBody = b.replay
+		return b
+	},
+}
+
+// buffer holds the encoded body of a single request attempt. It also serves
+// as the request body, so it goes back to the pool only once send has
+// released it and the transport has closed it and every body from getBody.
+type buffer struct {
+	data       []byte
+	compressed bytes.Buffer
+	keys       []string
+	payload    []byte
+	reader     bytes.Reader
+	refs       int32
+	closed     uint32
+	// getBody is b.replay, kept so that setting Request.GetBody does not
+	// allocate.
+	getBody func() (io.ReadCloser, error)
+}
+
+func getBuffer() *buffer {
+	b := bufferPool.Get().(*buffer)
+	b.refs = 1
+	b.closed = 0
+	return b
+}
+
+// body returns b as a request body reading p, which must be backed by b.
+func (b *buffer) body(p []byte) io.ReadCloser {
+	atomic.AddInt32(&b.refs, 1)
+	b.payload = p
+	b.reader.Reset(p)
+	return b
+}
+
+// replay returns another body reading the payload, for Request.GetBody, so
+// the transport can resend the request on a new connection.
+func (b *buffer) replay() (io.ReadCloser, error) {
+	atomic.AddInt32(&b.refs, 1)
+	rb := &replayBody{b: b}
+	rb.Reset(b.payload)
+	return rb, nil
+}
+
+type replayBody struct {
+	bytes.Reader
+	b      *buffer
+	closed uint32
+}
+
+func (rb *replayBody) Close() error {
+	if atomic.CompareAndSwapUint32(&rb.closed, 0, 1) {
+		rb.b.release()
+	}
+	return nil
+}
+
+func (b *buffer) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
+
+func (b *buffer) Close() error {
+	if atomic.CompareAndSwapUint32(&b.closed, 0, 1) {
+		b.release()
+	}
+	return nil
+}
+
+func (b *buffer) release() {
+	if atomic.AddInt32(&b.refs, -1) != 0 {
+		return
+	}
+	if cap(b.data) > _MAX_POOLED_BUFFER || b.compressed.Cap() > _MAX_POOLED_BUFFER {
+		return
+	}
+
+	for i := range b.keys {
+		b.keys[i] = ""
+	}
+	b.keys = b.keys[:0]
+	b.data = b.data[:0]
+	b.payload = nil
+	b.compressed.Reset()
+	b.reader.Reset(nil)
+	bufferPool.Put(b)
+}
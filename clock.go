@@ -0,0 +1,58 @@
+package hawkflow
+
+import "time"
+
+// Clock tells the client the time. Tests can pass their own to OptionClock
+// for deterministic timestamps and durations.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// OptionClock replaces the system clock used for timestamps, Timer
+// durations, the circuit breaker cooldown, the rate limiter and the expiry of
+// sampling decisions.
+func OptionClock(c Clock) func(*client) {
+	return func(hfc *client) {
+		if c == nil {
+			c = systemClock{}
+		}
+		hfc.clock = c
+	}
+}
+
+// OptionTimestamps stamps every event with the time it occurred, so
+// durations are not skewed by network latency or retries and replayed events
+// keep their original time.
+func OptionTimestamps(b bool) func(*client) {
+	return func(hfc *client) { hfc.timestamps = b }
+}
+
+// applyClock hands the clock to the parts of the client keeping time.
+func (hfc *client) applyClock() {
+	if _, ok := hfc.clock.(systemClock); ok {
+		return
+	}
+	if hfc.breaker != nil {
+		hfc.breaker.now = hfc.clock.Now
+	}
+	if hfc.limiter != nil {
+		hfc.limiter.now = hfc.clock.Now
+	}
+	if hfc.sampling != nil {
+		hfc.sampling.now = hfc.clock.Now
+	}
+}
+
+func (hfc *client) stamp(r *request) {
+	if hfc.timestamps && r.Timestamp == "" {
+		r.Timestamp = formatTimestamp(hfc.clock.Now())
+	}
+}
+
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,71 @@
+package hawkflow
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sync"
+)
+
+// Compressor compresses request bodies. Implement it to plug in other
+// encodings such as zstd.
+type Compressor interface {
+	// Encoding is the Content-Encoding header value, e.g. "gzip".
+	Encoding() string
+	// Compress writes src compressed to dst.
+	Compress(dst *bytes.Buffer, src []byte) error
+}
+
+type gzipCompressor struct {
+	level int
+	pool  sync.Pool
+}
+
+// GzipCompressor returns a Compressor reusing pooled gzip writers. An invalid
+// level falls back to gzip.DefaultCompression.
+func GzipCompressor(level int) Compressor {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	return &gzipCompressor{level: level}
+}
+
+func (g *gzipCompressor) Encoding() string {
+	return "gzip"
+}
+
+func (g *gzipCompressor) Compress(dst *bytes.Buffer, src []byte) error {
+	zw, ok := g.pool.Get().(*gzip.Writer)
+	if ok {
+		zw.Reset(dst)
+	} else {
+		zw, _ = gzip.NewWriterLevel(dst, g.level)
+	}
+	defer g.pool.Put(zw)
+
+	if _, err := zw.Write(src); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// OptionCompression compresses request bodies of at least threshold bytes
+// with c. Smaller bodies are sent uncompressed.
+func OptionCompression(c Compressor, threshold int) func(*client) {
+	return func(hfc *client) {
+		hfc.compressor = c
+		hfc.compressThreshold = threshold
+	}
+}
+
+// compress returns the bytes of buf to send and their Content-Encoding, which
+// is empty if buf.data was left uncompressed.
+func (hfc *client) compress(buf *buffer) ([]byte, string, error) {
+	if hfc.compressor == nil || len(buf.data) < hfc.compressThreshold {
+		return buf.data, "", nil
+	}
+
+	if err := hfc.compressor.Compress(&buf.compressed, buf.data); err != nil {
+		return nil, "", err
+	}
+	return buf.compressed.Bytes(), hfc.compressor.Encoding(), nil
+}
@@ -0,0 +1,107 @@
+package hawkflow
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestOptionCompression(t *testing.T) {
+	longMessage := strings.Repeat("Traceback line ", 100)
+
+	testCases := map[string]struct {
+		message          string
+		expectedEncoding string
+		expectedBody     string
+	}{
+		"Small body is sent uncompressed": {
+			message:          "short",
+			expectedEncoding: "",
+			expectedBody:     `{"process":"test_process","exception":"short"}`,
+		},
+		"Large body is compressed": {
+			message:          longMessage,
+			expectedEncoding: "gzip",
+			expectedBody:     `{"process":"test_process","exception":"` + longMessage + `"}`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := &ClientMock{returnStatusCode: 201}
+			hfc := New("api_key", OptionHTTPClient(c), OptionCompression(GzipCompressor(gzip.BestSpeed), 512))
+			if err := hfc.Exception("test_process", "", testCase.message); err != nil {
+				t.Fatal(err)
+			}
+
+			encoding := c.request.Header.Get("content-encoding")
+			if encoding != testCase.expectedEncoding {
+				t.Errorf("%v expected, got %v", testCase.expectedEncoding, encoding)
+			}
+
+			var body io.Reader = c.request.Body
+			if encoding == "gzip" {
+				zr, err := gzip.NewReader(body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			}
+			reqBody, _ := io.ReadAll(body)
+			if strings.TrimSpace(string(reqBody)) != testCase.expectedBody {
+				t.Errorf("%v expected, got %v", testCase.expectedBody, strings.TrimSpace(string(reqBody)))
+			}
+		})
+	}
+}
+
+func TestGzipCompressor(t *testing.T) {
+	testCases := map[string]struct {
+		level int
+	}{
+		"Default level":    {level: gzip.DefaultCompression},
+		"Best speed":       {level: gzip.BestSpeed},
+		"Invalid level":    {level: 42},
+		"Huffman only":     {level: gzip.HuffmanOnly},
+		"Best compression": {level: gzip.BestCompression},
+	}
+
+	src := []byte(strings.Repeat(`{"process":"test_process"}`, 50))
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := GzipCompressor(testCase.level)
+			if c.Encoding() != "gzip" {
+				t.Errorf("%v expected, got %v", "gzip", c.Encoding())
+			}
+
+			// Compress twice to exercise the pooled writer.
+			for i := 0; i < 2; i++ {
+				dst := new(bytes.Buffer)
+				if err := c.Compress(dst, src); err != nil {
+					t.Fatal(err)
+				}
+				zr, err := gzip.NewReader(dst)
+				if err != nil {
+					t.Fatal(err)
+				}
+				out, _ := io.ReadAll(zr)
+				if !bytes.Equal(out, src) {
+					t.Errorf("round trip failed")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGzipCompressor(b *testing.B) {
+	c := GzipCompressor(gzip.DefaultCompression)
+	src := []byte(strings.Repeat(`{"process":"test_process","items":{"key":123}}`, 20))
+	dst := new(bytes.Buffer)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dst.Reset()
+		_ = c.Compress(dst, src)
+	}
+}
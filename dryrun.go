@@ -0,0 +1,48 @@
+package hawkflow
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type dryRun struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// OptionDryRun validates and encodes every event as usual, but writes the
+// request that would have been sent to w instead of calling the API. The API
+// key and the random idempotency key headers are left out, and so is
+// content-encoding because the body is written uncompressed.
+func OptionDryRun(w io.Writer) func(*client) {
+	return func(hfc *client) { hfc.dryRun = &dryRun{w: w} }
+}
+
+func (d *dryRun) write(req *http.Request, body []byte) error {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "%s %s\n", req.Method, req.URL)
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		if strings.EqualFold(k, "x-hawkflow-api-key") || strings.EqualFold(k, "idempotency-key") ||
+			strings.EqualFold(k, "content-encoding") {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%s: %s\n", strings.ToLower(k), strings.Join(req.Header[k], ", "))
+	}
+	fmt.Fprintf(buf, "\n%s\n", bytes.TrimSpace(body))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,95 @@
+package hawkflow
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func TestOptionDryRun(t *testing.T) {
+	testCases := map[string]struct {
+		send   func(hfc *client) error
+		output string
+		error  string
+	}{
+		"Start is written": {
+			send: func(hfc *client) error { return hfc.Start("test_process", "test_meta", "") },
+			output: "POST https://api.hawkflow.ai/v1/start\n" +
+				"content-type: application/json\n" +
+				"user-agent: " + userAgent("") + "\n" +
+				"x-hawkflow-schema-version: 1\n" +
+				"x-hawkflow-sdk-version: " + Version + "\n" +
+				"\n" +
+				`{"process":"test_process","meta":"test_meta"}` + "\n",
+		},
+		"Metrics are written": {
+			send: func(hfc *client) error {
+				return hfc.Metrics("test_process", "", map[string]float64{"key": 123})
+			},
+			output: "POST https://api.hawkflow.ai/v1/metrics\n" +
+				"content-type: application/json\n" +
+				"user-agent: " + userAgent("") + "\n" +
+				"x-hawkflow-schema-version: 1\n" +
+				"x-hawkflow-sdk-version: " + Version + "\n" +
+				"\n" +
+				`{"process":"test_process","items":{"key":123}}` + "\n",
+		},
+		"Invalid event is still rejected": {
+			send:  func(hfc *client) error { return hfc.Start("invalid process ❌", "", "") },
+			error: "Process parameter contains unsupported characters. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := &ClientMock{returnStatusCode: 500}
+			hfc := New("api_key", OptionHTTPClient(c), OptionDryRun(buf))
+			err := testCase.send(hfc)
+			errorMsg := ""
+			if err != nil {
+				errorMsg = err.Error()
+			}
+
+			if c.count != 0 {
+				t.Errorf("%v expected, got %v", 0, c.count)
+			}
+			if buf.String() != testCase.output {
+				t.Errorf("%q expected, got %q", testCase.output, buf.String())
+			}
+			if errorMsg != testCase.error {
+				t.Errorf("%v expected, got %v", testCase.error, errorMsg)
+			}
+		})
+	}
+}
+
+func TestOptionDryRunCompressed(t *testing.T) {
+	buf := new(bytes.Buffer)
+	hfc := New("api_key", OptionHTTPClient(&ClientMock{}), OptionDryRun(buf), OptionCompression(GzipCompressor(gzip.DefaultCompression), 0))
+	if err := hfc.Start("test_process", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "content-encoding") {
+		t.Errorf("no content-encoding expected for the uncompressed body, got %q", buf.String())
+	}
+	if !strings.HasSuffix(buf.String(), `{"process":"test_process"}`+"\n") {
+		t.Errorf("uncompressed body expected, got %q", buf.String())
+	}
+}
+
+func TestOptionDryRunValidatesApiKey(t *testing.T) {
+	buf := new(bytes.Buffer)
+	hfc := New("", OptionDryRun(buf))
+	err := hfc.Start("test_process", "", "")
+
+	expected := "No API Key set. Please see documentation at https://docs.hawkflow.ai/integration/index.html"
+	if err == nil || err.Error() != expected {
+		t.Errorf("%v expected, got %v", expected, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("nothing expected, got %v", buf.String())
+	}
+}
@@ -0,0 +1,176 @@
+package hawkflow
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+const _HEX = "0123456789abcdef"
+
+// appendRequest appends the JSON encoding of r to dst without reflection or
+// allocation; keys is scratch space for sorting item keys. The output matches
+// encoding/json byte for byte, except for \b, \f and invalid UTF-8, whose
+// escaping differs between Go releases; those always decode the same.
+func appendRequest(dst []byte, r *request, keys []string) ([]byte, []string, error) {
+	if r == nil {
+		return append(dst, "null"...), keys, nil
+	}
+
+	dst = append(dst, `{"process":`...)
+	dst = appendString(dst, r.Process)
+	if r.Meta != "" {
+		dst = append(dst, `,"meta":`...)
+		dst = appendString(dst, r.Meta)
+	}
+	if r.UID != "" {
+		dst = append(dst, `,"uid":`...)
+		dst = appendString(dst, r.UID)
+	}
+	if r.ExceptionMessage != "" {
+		dst = append(dst, `,"exception":`...)
+		dst = appendString(dst, r.ExceptionMessage)
+	}
+	if len(r.Items) > 0 {
+		keys = keys[:0]
+		for k := range r.Items {
+			keys = append(keys, k)
+		}
+		sortStrings(keys)
+
+		dst = append(dst, `,"items":{`...)
+		for i, k := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendString(dst, k)
+			dst = append(dst, ':')
+			var err error
+			if dst, err = appendFloat(dst, r.Items[k]); err != nil {
+				return dst, keys, err
+			}
+		}
+		dst = append(dst, '}')
+	}
+	if r.SampleRate != 0 {
+		dst = append(dst, `,"sample_rate":`...)
+		var err error
+		if dst, err = appendFloat(dst, r.SampleRate); err != nil {
+			return dst, keys, err
+		}
+	}
+	if r.Timestamp != "" {
+		dst = append(dst, `,"timestamp":`...)
+		dst = appendString(dst, r.Timestamp)
+	}
+	if r.DurationMs != 0 {
+		dst = append(dst, `,"duration_ms":`...)
+		var err error
+		if dst, err = appendFloat(dst, r.DurationMs); err != nil {
+			return dst, keys, err
+		}
+	}
+	if r.ParentUID != "" {
+		dst = append(dst, `,"parent_uid":`...)
+		dst = appendString(dst, r.ParentUID)
+	}
+	if r.Path != "" {
+		dst = append(dst, `,"path":`...)
+		dst = appendString(dst, r.Path)
+	}
+	if r.Failed {
+		dst = append(dst, `,"failed":true`...)
+	}
+
+	return append(dst, '}'), keys, nil
+}
+
+// sortStrings is an insertion sort, which is allocation free and fast for
+// the few dozen items of a Metrics call.
+func sortStrings(s []string) {
+	for i := 1; i < len(s); i++ {
+		for j := i; j > 0 && s[j] < s[j-1]; j-- {
+			s[j], s[j-1] = s[j-1], s[j]
+		}
+	}
+}
+
+// appendFloat formats f like encoding/json.
+func appendFloat(dst []byte, f float64) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return dst, &json.UnsupportedValueError{Str: strconv.FormatFloat(f, 'g', -1, 64)}
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, 64)
+
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+
+	return dst, nil
+}
+
+// appendString quotes s like encoding/json, including its HTML escaping. \b
+// and \f are written as short escapes and invalid UTF-8 as \ufffd.
+func appendString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= ' ' && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', _HEX[b>>4], _HEX[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if c == '\u2028' || c == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', _HEX[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+
+	return append(dst, '"')
+}
@@ -0,0 +1,150 @@
+package hawkflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestAppendRequest(t *testing.T) {
+	testCases := map[string]*request{
+		"Nil request":     nil,
+		"Empty request":   {},
+		"Process only":    {Process: "test_process"},
+		"All fields":      {Process: "test_process", Meta: "test_meta", UID: "test_uid", ExceptionMessage: "message"},
+		"Escaped string":  {Process: "p", ExceptionMessage: "quote \" backslash \\ html <a href='x'>&</a> ctl \n\r\t\x00\x1f"},
+		"Unicode":         {Process: "p", ExceptionMessage: "naïve café ❌ line para  end"},
+		"Long exception":  {Process: "p", ExceptionMessage: strings.Repeat("Traceback (most recent call last):\n", 400)},
+		"Empty items":     {Process: "p", Items: map[string]float64{}},
+		"Sorted items":    {Process: "p", Items: map[string]float64{"b": 2, "a": 1, "c": 3, "B": 0, "a_1": -1}},
+		"Float formats":   {Process: "p", Items: map[string]float64{"int": 123, "neg": -0.5, "small": 1e-7, "tiny": 5e-324, "big": 1e21, "almost": 999999999999999999999, "max": math.MaxFloat64, "zero": 0, "negzero": math.Copysign(0, -1), "frac": 0.1 + 0.2}},
+		"Sample rate":     {Process: "p", UID: "u", SampleRate: 0.25},
+		"Timestamps":      {Process: "p", UID: "u", Timestamp: "2024-01-02T03:04:05.123456789Z", DurationMs: 1234.567},
+		"Span":            {Process: "stage", UID: "u2", ParentUID: "u1", Path: "job/stage", Failed: true, DurationMs: 1},
+		"Item key quoted": {Process: "p", Items: map[string]float64{"weird \"key\" <>": 1}},
+	}
+
+	for name, r := range testCases {
+		t.Run(name, func(t *testing.T) {
+			expected, err := json.Marshal(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := appendRequest(nil, r, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, expected) {
+				t.Errorf("%s expected, got %s", expected, got)
+			}
+		})
+	}
+}
+
+// encoding/json output for \b, \f and invalid UTF-8 differs between Go
+// releases, so the encoding is checked directly and by decoding it.
+func TestAppendRequestReleaseDependentEscapes(t *testing.T) {
+	testCases := map[string]struct {
+		message  string
+		encoded  string
+		expected string
+	}{
+		"Backspace and form feed": {
+			message:  "ctl \b\f end",
+			encoded:  `{"process":"p","exception":"ctl \b\f end"}`,
+			expected: "ctl \b\f end",
+		},
+		"Invalid UTF-8": {
+			message:  "invalid \xff\xfe end",
+			encoded:  `{"process":"p","exception":"invalid \ufffd\ufffd end"}`,
+			expected: "invalid \ufffd\ufffd end",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, _, err := appendRequest(nil, &request{Process: "p", ExceptionMessage: testCase.message}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != testCase.encoded {
+				t.Errorf("%s expected, got %s", testCase.encoded, got)
+			}
+
+			r := &request{}
+			if err := json.Unmarshal(got, r); err != nil {
+				t.Fatal(err)
+			}
+			if r.ExceptionMessage != testCase.expected {
+				t.Errorf("%q expected, got %q", testCase.expected, r.ExceptionMessage)
+			}
+		})
+	}
+}
+
+func TestAppendRequestNonFinite(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		_, _, err := appendRequest(nil, &request{Process: "p", Items: map[string]float64{"key": f}}, nil)
+		_, expected := json.Marshal(f)
+
+		if err == nil || err.Error() != expected.Error() {
+			t.Errorf("%v expected, got %v", expected, err)
+		}
+	}
+}
+
+func TestBufferRelease(t *testing.T) {
+	buf := getBuffer()
+	buf.data = append(buf.data, "payload"...)
+	body := buf.body(buf.data)
+
+	buf.release()
+	if buf.refs != 1 {
+		t.Errorf("%v expected, got %v", 1, buf.refs)
+	}
+
+	out := new(bytes.Buffer)
+	_, _ = out.ReadFrom(body)
+	if out.String() != "payload" {
+		t.Errorf("%v expected, got %v", "payload", out.String())
+	}
+
+	replayed, _ := buf.getBody()
+	_ = body.Close()
+	_ = body.Close()
+	if buf.refs != 1 {
+		t.Errorf("%v expected, got %v", 1, buf.refs)
+	}
+
+	out.Reset()
+	_, _ = out.ReadFrom(replayed)
+	if out.String() != "payload" {
+		t.Errorf("%v expected, got %v", "payload", out.String())
+	}
+	_ = replayed.Close()
+	_ = replayed.Close()
+	if buf.refs != 0 {
+		t.Errorf("%v expected, got %v", 0, buf.refs)
+	}
+}
+
+func BenchmarkAppendRequest(b *testing.B) {
+	r := &request{Process: "test_process", Meta: "test_meta", Items: map[string]float64{"rows": 12, "cols": 3, "size": 1024.5}}
+	var dst []byte
+	var keys []string
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dst, keys, _ = appendRequest(dst[:0], r, keys)
+	}
+}
+
+func BenchmarkJSONEncoder(b *testing.B) {
+	r := &request{Process: "test_process", Meta: "test_meta", Items: map[string]float64{"rows": 12, "cols": 3, "size": 1024.5}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = json.NewEncoder(new(bytes.Buffer)).Encode(r)
+	}
+}
@@ -1,14 +1,16 @@
 package hawkflow
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,19 +18,59 @@ const (
 	// _ENDPOINT must end with backslash
 	_ENDPOINT = "https://api.hawkflow.ai/v1/"
 	_TIMEOUT  = 1000 * time.Millisecond
+
+	// _MAX_RESPONSE_SIZE bounds how much of a response body is read.
+	_MAX_RESPONSE_SIZE = 64 << 10
 )
 
+var _CONTENT_TYPE = []string{"application/json"}
+
 type httpClient interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
 type client struct {
-	apiKey     string
-	endpoint   string
-	maxRetries uint8
-	debug      bool
-	logger     logger
-	httpClient httpClient
+	apiKey      string
+	endpoint    string
+	maxRetries  uint8
+	debug       bool
+	logger      logger
+	httpClient  httpClient
+	timeout     time.Duration
+	timeoutSet  bool
+	traffic     *trafficLog
+	dryRun      *dryRun
+	sanitize    bool
+	nonFinite   NonFinitePolicy
+	validateAll bool
+	limits      LimitSet
+	seq         uint64
+
+	remoteLimits bool
+	limitsCache  string
+
+	identity identity
+	headers  http.Header
+	urls     map[string]*url.URL
+
+	compressor        Compressor
+	compressThreshold int
+
+	transport transportConfig
+	configErr error
+
+	breaker         *breaker
+	onCircuitChange func(from, to CircuitState)
+	limiter         *limiter
+	sampling        *sampler
+
+	clock       Clock
+	timestamps  bool
+	traceparent bool
+
+	// requestTimeout is the per request deadline for custom HTTP clients
+	// that are not an *http.Client.
+	requestTimeout time.Duration
 }
 
 type logger interface {
@@ -42,6 +84,16 @@ type request struct {
 	UID              string             `json:"uid,omitempty"`
 	ExceptionMessage string             `json:"exception,omitempty"`
 	Items            map[string]float64 `json:"items,omitempty"`
+	SampleRate       float64            `json:"sample_rate,omitempty"`
+	Timestamp        string             `json:"timestamp,omitempty"`
+	DurationMs       float64            `json:"duration_ms,omitempty"`
+	ParentUID        string             `json:"parent_uid,omitempty"`
+	Path             string             `json:"path,omitempty"`
+	Failed           bool               `json:"failed,omitempty"`
+
+	seq uint64
+	// key is the idempotency key shared by all attempts of the event.
+	key string
 }
 
 type option func(*client)
@@ -50,9 +102,13 @@ func OptionMaxRetries(maxRetries uint8) func(*client) {
 	return func(hfc *client) { hfc.maxRetries = maxRetries }
 }
 
-// OptionTimeout overwrites httpClient
+// OptionTimeout sets the request timeout, also for a client passed to
+// OptionHTTPClient. It defaults to 1s for the built-in client; 0 disables it.
 func OptionTimeout(timeout time.Duration) func(*client) {
-	return func(hfc *client) { hfc.httpClient = &http.Client{Timeout: timeout} }
+	return func(hfc *client) {
+		hfc.timeout = timeout
+		hfc.timeoutSet = true
+	}
 }
 
 func OptionDebug(b bool) func(*client) {
@@ -65,124 +121,170 @@ func OptionLogger(l logger) func(*client) {
 	}
 }
 
+// OptionValidateAll reports every invalid field of an event instead of only the first
+func OptionValidateAll(b bool) func(*client) {
+	return func(hfc *client) { hfc.validateAll = b }
+}
+
 func OptionHTTPClient(c httpClient) func(*client) {
 	return func(hfc *client) { hfc.httpClient = c }
 }
 
+// OptionEndpoint overwrites the API endpoint, e.g. to point at a local fake server
+func OptionEndpoint(endpoint string) func(*client) {
+	return func(hfc *client) {
+		if !strings.HasSuffix(endpoint, "/") {
+			endpoint += "/"
+		}
+		hfc.endpoint = endpoint
+	}
+}
+
 func New(apiKey string, options ...option) *client {
 	hfc := &client{
 		apiKey:     apiKey,
 		endpoint:   _ENDPOINT,
+		limits:     defaultLimits,
+		clock:      systemClock{},
 		maxRetries: 3,
 		debug:      false,
 		logger:     log.New(os.Stderr, "hawkflow", log.LstdFlags|log.Lshortfile),
 		httpClient: &http.Client{
-			Timeout: _TIMEOUT,
+			Transport: defaultTransport,
+			Timeout:   _TIMEOUT,
 		},
-
-		//// https://github.com/hashicorp/go-cleanhttp/blob/02f12f05b908335f65f124c1a7f1ec45f5c42a35/cleanhttp.go#L26
-		//httpClient: &http.Client{
-		//	Transport: &http.Transport{
-		//		MaxIdleConns:          100,
-		//		MaxIdleConnsPerHost:   runtime.GOMAXPROCS(0) + 1,
-		//		IdleConnTimeout:       3 * time.Second,
-		//		TLSHandshakeTimeout:   1 * time.Second,
-		//		ExpectContinueTimeout: 1 * time.Second,
-		//		ForceAttemptHTTP2:     true,
-		//	},
-		//	Timeout: 1 * time.Second,
-		//},
 	}
 
 	for _, opt := range options {
 		opt(hfc)
 	}
+	hfc.applyClock()
+	hfc.configureTransport()
+	hfc.applyTimeout()
+
+	hfc.buildHeaders()
+	hfc.urls = make(map[string]*url.URL)
+	for _, path := range []string{"start", "end", "exception", "metrics"} {
+		if u, err := url.Parse(hfc.endpoint + path); err == nil {
+			hfc.urls[path] = u
+		}
+	}
+
+	if hfc.remoteLimits {
+		hfc.loadRemoteLimits()
+	}
 
 	return hfc
 }
 
+func (hfc *client) validate(path string, r *request) error {
+	return hfc.limits.validate(path, r, hfc.validateAll)
+}
+
 func (hfc *client) log(m string) {
 	if hfc.debug {
 		hfc.logger.Print(fmt.Sprintf("HF %s\n", m))
 	}
 }
 
+// logf formats only when debug is enabled. On hot paths guard calls with
+// hfc.debug too, as boxing the arguments allocates.
+func (hfc *client) logf(format string, args ...interface{}) {
+	if hfc.debug {
+		hfc.logger.Print("HF " + fmt.Sprintf(format, args...) + "\n")
+	}
+}
+
 func (hfc *client) Start(process, meta, uid string) error {
-	r := &request{
+	return hfc.event("start", &request{
 		Process: process,
 		Meta:    meta,
 		UID:     uid,
-	}
-
-	err := validateTimedData(r)
-	if err != nil {
-		return err
-	}
-
-	hfc.log(fmt.Sprintf("Start: %s", process))
-
-	return hfc.sendWithRetry(r, "start", hfc.maxRetries)
+	})
 }
 
 func (hfc *client) End(process, meta, uid string) error {
-	r := &request{
+	return hfc.event("end", &request{
 		Process: process,
 		Meta:    meta,
 		UID:     uid,
-	}
-
-	err := validateTimedData(r)
-	if err != nil {
-		return err
-	}
-
-	hfc.log(fmt.Sprintf("End: %s", process))
-
-	return hfc.sendWithRetry(r, "end", hfc.maxRetries)
+	})
 }
 
 func (hfc *client) Exception(process, meta, message string) error {
-	r := &request{
+	return hfc.event("exception", &request{
 		Process:          process,
 		Meta:             meta,
 		ExceptionMessage: message,
+	})
+}
+
+func (hfc *client) Metrics(process, meta string, items map[string]float64) error {
+	return hfc.event("metrics", &request{
+		Process: process,
+		Meta:    meta,
+		Items:   hfc.repairItems(items),
+	})
+}
+
+// event stamps, sanitizes and validates r before delivering it to path.
+func (hfc *client) event(path string, r *request) error {
+	hfc.stamp(r)
+
+	if hfc.sanitize {
+		hfc.sanitizeRequest(r)
 	}
 
-	err := validateException(r)
+	err := hfc.validate(path, r)
 	if err != nil {
 		return err
 	}
 
-	hfc.log(fmt.Sprintf("Exception: %s", process))
+	if hfc.debug {
+		hfc.logf("%s: %s", eventNames[path], r.Process)
+	}
 
-	return hfc.sendWithRetry(r, "exception", hfc.maxRetries)
+	return hfc.deliver(r, path)
 }
 
-func (hfc *client) Metrics(process, meta string, items map[string]float64) error {
-	r := &request{
-		Process: process,
-		Meta:    meta,
-		Items:   items,
+var eventNames = map[string]string{
+	"start":     "Start",
+	"end":       "End",
+	"exception": "Exception",
+	"metrics":   "Metrics",
+}
+
+// deliver sends a validated event, subject to sampling and the rate limit.
+func (hfc *client) deliver(r *request, path string) error {
+	if !hfc.sample(path, r) {
+		return nil
 	}
+	return hfc.deliverSampled(r, path)
+}
 
-	err := validateMetrics(r)
-	if err != nil {
+// deliverSampled sends a validated event that has passed sampling, subject to
+// the rate limit.
+func (hfc *client) deliverSampled(r *request, path string) error {
+	if ok, err := hfc.limit(path, r); !ok {
 		return err
 	}
-
-	hfc.log(fmt.Sprintf("Metrics: %s", process))
-
-	return hfc.sendWithRetry(r, "metrics", hfc.maxRetries)
+	return hfc.sendWithRetry(r, path, hfc.maxRetries)
 }
 
 func (hfc *client) sendWithRetry(r *request, path string, count uint8) error {
 	if 0 >= count {
 		return createError("Connection failed permanently.")
 	}
+	if r.seq == 0 {
+		r.seq = atomic.AddUint64(&hfc.seq, 1)
+	}
+	if r.key == "" {
+		r.key = newIdempotencyKey()
+	}
 
 	retry, err := hfc.send(r, path)
 	if nil != err {
-		hfc.log(fmt.Sprintf("Connection failed on attempt %d with error: %s", count, err))
+		hfc.logf("Connection failed on attempt %d with error: %s", count, err)
 		if !retry {
 			return err
 		}
@@ -193,37 +295,113 @@ func (hfc *client) sendWithRetry(r *request, path string, count uint8) error {
 }
 
 func (hfc *client) send(r *request, path string) (bool, error) {
-	err := validateApiKey(hfc.apiKey)
+	err := hfc.limits.validateApiKey(hfc.apiKey)
 	if err != nil {
 		return false, err
 	}
+	if hfc.configErr != nil {
+		return false, hfc.configErr
+	}
 
-	body := new(bytes.Buffer)
-	err = json.NewEncoder(body).Encode(r)
+	buf := getBuffer()
+	defer buf.release()
+
+	buf.data, buf.keys, err = appendRequest(buf.data, r, buf.keys)
 	if err != nil {
 		return false, err
 	}
 
-	hfc.log(fmt.Sprintf("Requesting path: %s", path))
-	hfc.log(fmt.Sprintf("Sending data: %s", body))
+	if hfc.debug {
+		hfc.logf("Requesting path: %s", path)
+		hfc.logf("Sending data: %s", buf.data)
+	}
 
-	req, err := http.NewRequest("POST", hfc.endpoint+path, body)
+	payload, encoding, err := hfc.compress(buf)
 	if err != nil {
 		return false, err
 	}
 
-	req.Header.Set("content-type", "application/json")
-	req.Header.Set("x-hawkflow-api-key", hfc.apiKey)
+	u, ok := hfc.urls[path]
+	if !ok {
+		if u, err = url.Parse(hfc.endpoint + path); err != nil {
+			return false, err
+		}
+	}
 
-	resp, err := hfc.httpClient.Do(req)
+	req := &http.Request{
+		Method:        "POST",
+		URL:           u,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        hfc.header(3),
+		ContentLength: int64(len(payload)),
+		Host:          u.Host,
+	}
+	req.Header["Content-Type"] = _CONTENT_TYPE
+	if r != nil && r.key != "" {
+		req.Header["Idempotency-Key"] = []string{r.key}
+	}
+	if encoding != "" {
+		req.Header.Set("content-encoding", encoding)
+	}
+
+	if hfc.dryRun != nil {
+		hfc.log("Dry run, request not sent")
+		return false, hfc.dryRun.write(req, buf.data)
+	}
+
+	generation, err := hfc.circuitAllow()
+	if err != nil {
+		return false, err
+	}
+
+	var rec *TrafficRecord
+	if hfc.traffic != nil {
+		rec = &TrafficRecord{
+			Time:     time.Now(),
+			Event:    path,
+			Endpoint: u.String(),
+			Body:     append(json.RawMessage(nil), buf.data...),
+		}
+		if r != nil {
+			rec.Seq = r.seq
+		}
+	}
+
+	req.Body = buf.body(payload)
+	req.GetBody = buf.getBody
+	resp, err := hfc.do(req)
 	if err != nil {
+		hfc.circuitDone(generation, 0, err)
+		if rec != nil {
+			rec.LatencyMs = float64(time.Since(rec.Time)) / float64(time.Millisecond)
+			rec.Error = err.Error()
+			hfc.recordTraffic(rec, req)
+		}
 		return true, err
 	}
 	defer resp.Body.Close()
-	respBody, _ := io.ReadAll(resp.Body)
+	hfc.circuitDone(generation, resp.StatusCode, nil)
 
-	hfc.log(fmt.Sprintf("Response Status: %s", resp.Status))
-	hfc.log(fmt.Sprintf("Response Body: %s", respBody))
+	var respBody []byte
+	if http.StatusCreated != resp.StatusCode || hfc.debug || rec != nil {
+		respBody, _ = io.ReadAll(io.LimitReader(resp.Body, _MAX_RESPONSE_SIZE))
+	}
+	// Drain what is left so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, _MAX_RESPONSE_SIZE))
+
+	if rec != nil {
+		rec.LatencyMs = float64(time.Since(rec.Time)) / float64(time.Millisecond)
+		rec.Status = resp.StatusCode
+		rec.Response = string(respBody)
+		hfc.recordTraffic(rec, req)
+	}
+
+	if hfc.debug {
+		hfc.logf("Response Status: %s", resp.Status)
+		hfc.logf("Response Body: %s", respBody)
+	}
 
 	if http.StatusUnauthorized == resp.StatusCode {
 		return false, errors.New(string(respBody))
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"reflect"
 	"strings"
@@ -32,6 +33,29 @@ func (c *ClientMock) Do(req *http.Request) (*http.Response, error) {
 	}, nil
 }
 
+// The transport only resends requests on a new connection, e.g. after a
+// stale keep-alive connection or an HTTP/2 GOAWAY, if they have GetBody.
+func TestRequestGetBody(t *testing.T) {
+	c := &ClientMock{returnStatusCode: 201}
+	hfc := New("api_key", OptionHTTPClient(c))
+	if err := hfc.Start("test_process", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.request.GetBody == nil {
+		t.Fatal("GetBody expected")
+	}
+	body, err := c.request.GetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	b, _ := io.ReadAll(body)
+	if expected := `{"process":"test_process"}`; string(b) != expected {
+		t.Errorf("%v expected, got %s", expected, b)
+	}
+}
+
 func TestOptionMaxRetries(t *testing.T) {
 	maxRetries := uint8(7)
 	hfc := New("api_key", OptionMaxRetries(maxRetries))
@@ -50,6 +74,77 @@ func TestOptionTimeout(t *testing.T) {
 	}
 }
 
+func TestOptionTimeoutDisabled(t *testing.T) {
+	hfc := New("api_key", OptionTimeout(0))
+
+	if c := hfc.httpClient.(*http.Client); c.Timeout != 0 {
+		t.Errorf("no timeout expected, got %v", c.Timeout)
+	}
+	if c := New("api_key").httpClient.(*http.Client); c.Timeout != _TIMEOUT {
+		t.Errorf("%v expected, got %v", _TIMEOUT, c.Timeout)
+	}
+}
+
+func TestOptionTimeoutKeepsHTTPClient(t *testing.T) {
+	transport := &http.Transport{}
+	custom := &http.Client{Transport: transport}
+
+	for name, options := range map[string][]option{
+		"Timeout first":     {OptionTimeout(123 * time.Millisecond), OptionHTTPClient(custom)},
+		"HTTP client first": {OptionHTTPClient(custom), OptionTimeout(123 * time.Millisecond)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			hfc := New("api_key", options...)
+
+			c, ok := hfc.httpClient.(*http.Client)
+			if !ok {
+				t.Fatalf("*http.Client expected, got %T", hfc.httpClient)
+			}
+			if c.Transport != transport {
+				t.Errorf("custom transport expected, got %v", c.Transport)
+			}
+			if c.Timeout != 123*time.Millisecond {
+				t.Errorf("%v expected, got %v", 123*time.Millisecond, c.Timeout)
+			}
+			if custom.Timeout != 0 {
+				t.Errorf("supplied client must not be modified, got timeout %v", custom.Timeout)
+			}
+		})
+	}
+}
+
+// blockingDoer waits for the request context like a real transport would.
+type blockingDoer struct{}
+
+func (blockingDoer) Do(req *http.Request) (*http.Response, error) {
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestOptionTimeoutCustomDoer(t *testing.T) {
+	hfc := New("api_key", OptionHTTPClient(blockingDoer{}), OptionTimeout(20*time.Millisecond), OptionMaxRetries(1))
+
+	started := time.Now()
+	if err := hfc.Start("test_process", "", ""); err == nil {
+		t.Errorf("error expected, got nil")
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("request deadline expected, took %v", elapsed)
+	}
+}
+
+func TestDefaultTransport(t *testing.T) {
+	hfc := New("api_key")
+
+	c := hfc.httpClient.(*http.Client)
+	if c.Transport != defaultTransport {
+		t.Errorf("default transport expected, got %v", c.Transport)
+	}
+	if c.Timeout != _TIMEOUT {
+		t.Errorf("%v expected, got %v", _TIMEOUT, c.Timeout)
+	}
+}
+
 func TestOptionDebug(t *testing.T) {
 	hfc := New("api_key", OptionDebug(true))
 
@@ -646,3 +741,155 @@ func TestLog(t *testing.T) {
 		})
 	}
 }
+
+func TestOptionEndpoint(t *testing.T) {
+	testCases := map[string]struct {
+		endpoint    string
+		expectedUrl string
+	}{
+		"Endpoint with trailing slash": {
+			endpoint:    "http://127.0.0.1:8080/v1/",
+			expectedUrl: "http://127.0.0.1:8080/v1/start",
+		},
+		"Endpoint without trailing slash": {
+			endpoint:    "http://127.0.0.1:8080/v1",
+			expectedUrl: "http://127.0.0.1:8080/v1/start",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := &ClientMock{returnStatusCode: 201}
+			hfc := New("api_key", OptionHTTPClient(c), OptionEndpoint(testCase.endpoint))
+			_ = hfc.Start("test_process", "", "")
+
+			if c.request.URL.String() != testCase.expectedUrl {
+				t.Errorf("%v expected, got %v", testCase.expectedUrl, c.request.URL.String())
+			}
+		})
+	}
+}
+
+func TestOptionNonFinite(t *testing.T) {
+	testCases := map[string]struct {
+		policy              NonFinitePolicy
+		items               map[string]float64
+		expectedCount       uint8
+		expectedRequestBody string
+		error               string
+	}{
+		"Reject": {
+			policy:        NonFiniteReject,
+			items:         map[string]float64{"key": 1, "nan": math.NaN()},
+			expectedCount: 0,
+			error:         "Item nan value NaN is not a finite number. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+		},
+		"Drop": {
+			policy:              NonFiniteDrop,
+			items:               map[string]float64{"key": 1, "nan": math.NaN(), "inf": math.Inf(1)},
+			expectedCount:       1,
+			expectedRequestBody: `{"process":"test_process","items":{"key":1}}`,
+		},
+		"Drop every item": {
+			policy:        NonFiniteDrop,
+			items:         map[string]float64{"nan": math.NaN()},
+			expectedCount: 0,
+			error:         "No items set. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+		},
+		"Clamp": {
+			policy:              NonFiniteClamp,
+			items:               map[string]float64{"nan": math.NaN(), "inf": math.Inf(1), "ninf": math.Inf(-1)},
+			expectedCount:       1,
+			expectedRequestBody: `{"process":"test_process","items":{"inf":1.7976931348623157e+308,"nan":0,"ninf":-1.7976931348623157e+308}}`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := &ClientMock{returnStatusCode: 201}
+			hfc := New("api_key", OptionHTTPClient(c), OptionNonFinite(testCase.policy))
+			err := hfc.Metrics("test_process", "", testCase.items)
+			errorMsg := ""
+			if err != nil {
+				errorMsg = err.Error()
+			}
+
+			if nil != c.request {
+				reqBody, _ := io.ReadAll(c.request.Body)
+				if strings.TrimSpace(string(reqBody)) != testCase.expectedRequestBody {
+					t.Errorf("%v expected, got %v", testCase.expectedRequestBody, strings.TrimSpace(string(reqBody)))
+				}
+			}
+			if c.count != testCase.expectedCount {
+				t.Errorf("%v expected, got %v", testCase.expectedCount, c.count)
+			}
+			if errorMsg != testCase.error {
+				t.Errorf("%v expected, got %v", testCase.error, errorMsg)
+			}
+			if v, ok := testCase.items["nan"]; ok && !math.IsNaN(v) {
+				t.Errorf("caller's items were modified")
+			}
+		})
+	}
+}
+
+func TestOptionValidateAll(t *testing.T) {
+	testCases := map[string]struct {
+		validateAll bool
+		error       string
+	}{
+		"First invalid field only": {
+			validateAll: false,
+			error:       "Process parameter contains unsupported characters. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+		},
+		"Every invalid field": {
+			validateAll: true,
+			error:       "Process parameter contains unsupported characters. Meta parameter contains unsupported characters. UID parameter contains unsupported characters. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := &ClientMock{returnStatusCode: 201}
+			hfc := New("api_key", OptionHTTPClient(c), OptionValidateAll(testCase.validateAll))
+			err := hfc.Start("invalid process ❌", "invalid meta ❌", "invalid uid ❌")
+			errorMsg := ""
+			if err != nil {
+				errorMsg = err.Error()
+			}
+
+			if c.count != 0 {
+				t.Errorf("%v expected, got %v", 0, c.count)
+			}
+			if errorMsg != testCase.error {
+				t.Errorf("%v expected, got %v", testCase.error, errorMsg)
+			}
+		})
+	}
+}
+
+// discardDoer consumes and closes the request body like http.Transport does.
+type discardDoer struct{}
+
+func (discardDoer) Do(req *http.Request) (*http.Response, error) {
+	_, _ = io.Copy(io.Discard, req.Body)
+	_ = req.Body.Close()
+	return &http.Response{StatusCode: 201, Body: http.NoBody}, nil
+}
+
+func BenchmarkStart(b *testing.B) {
+	hfc := New("api_key", OptionHTTPClient(discardDoer{}))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = hfc.Start("test_process", "test_meta", "test_uid")
+	}
+}
+
+func BenchmarkMetrics(b *testing.B) {
+	hfc := New("api_key", OptionHTTPClient(discardDoer{}))
+	items := map[string]float64{"rows": 12, "cols": 3, "size": 1024.5}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = hfc.Metrics("test_process", "test_meta", items)
+	}
+}
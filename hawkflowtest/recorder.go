@@ -0,0 +1,282 @@
+// Package hawkflowtest provides helpers for testing code instrumented with
+// the HawkFlow client without building HTTP mocks.
+//
+//	rec := hawkflowtest.NewRecorder()
+//	hf := hawkflow.New("test_api_key", hawkflow.OptionHTTPClient(rec))
+//	// ... run the code under test with hf ...
+//	rec.AssertTimerClosed(t, "my_process")
+package hawkflowtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Event is a single call captured by a Recorder.
+type Event struct {
+	// Kind is the API path the event was sent to: start, end, exception or metrics.
+	Kind      string             `json:"-"`
+	Process   string             `json:"process"`
+	Meta      string             `json:"meta,omitempty"`
+	UID       string             `json:"uid,omitempty"`
+	Exception string             `json:"exception,omitempty"`
+	Items     map[string]float64 `json:"items,omitempty"`
+	// SampleRate is the sample rate of a sampled Start or End, 0 if unsampled.
+	SampleRate float64 `json:"sample_rate,omitempty"`
+	// Timestamp is the client-side time of the event, if it sent one.
+	Timestamp string `json:"timestamp,omitempty"`
+	// DurationMs is the client-measured duration of an End sent by a Timer.
+	DurationMs float64 `json:"duration_ms,omitempty"`
+	// ParentUID and Path place an event sent by a child timer in its run.
+	ParentUID string `json:"parent_uid,omitempty"`
+	Path      string `json:"path,omitempty"`
+	// Failed marks an End of a timer that reported an exception.
+	Failed bool `json:"failed,omitempty"`
+}
+
+// Recorder captures every event sent through it. It implements the Do method
+// expected by hawkflow.OptionHTTPClient and answers each request with
+// StatusCode, which defaults to 201 Created.
+type Recorder struct {
+	StatusCode int
+
+	mu     sync.Mutex
+	events []Event
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{StatusCode: http.StatusCreated}
+}
+
+func (rec *Recorder) Do(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	e := Event{Kind: path.Base(req.URL.Path)}
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, err
+	}
+
+	rec.mu.Lock()
+	rec.events = append(rec.events, e)
+	status := rec.StatusCode
+	rec.mu.Unlock()
+
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+		Request:    req,
+	}, nil
+}
+
+// Events returns a copy of every event recorded so far, in the order sent.
+func (rec *Recorder) Events() []Event {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	events := make([]Event, len(rec.events))
+	copy(events, rec.events)
+	return events
+}
+
+// Reset discards all recorded events.
+func (rec *Recorder) Reset() {
+	rec.mu.Lock()
+	rec.events = nil
+	rec.mu.Unlock()
+}
+
+func (rec *Recorder) filter(kind, process string) []Event {
+	var events []Event
+	for _, e := range rec.Events() {
+		if e.Kind == kind && e.Process == process {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// AssertStarted checks that at least one Start was sent for process.
+func (rec *Recorder) AssertStarted(t testing.TB, process string) bool {
+	t.Helper()
+	if len(rec.filter("start", process)) == 0 {
+		t.Errorf("hawkflowtest: expected Start for process %q, got none", process)
+		return false
+	}
+	return true
+}
+
+// AssertEnded checks that at least one End was sent for process.
+func (rec *Recorder) AssertEnded(t testing.TB, process string) bool {
+	t.Helper()
+	if len(rec.filter("end", process)) == 0 {
+		t.Errorf("hawkflowtest: expected End for process %q, got none", process)
+		return false
+	}
+	return true
+}
+
+// AssertTimerClosed checks that process was started and that every Start was
+// followed by an End with the same meta and uid.
+func (rec *Recorder) AssertTimerClosed(t testing.TB, process string) bool {
+	t.Helper()
+
+	type timer struct{ meta, uid string }
+	open := make(map[timer]int)
+	started := false
+	for _, e := range rec.Events() {
+		if e.Process != process {
+			continue
+		}
+		switch e.Kind {
+		case "start":
+			started = true
+			open[timer{e.Meta, e.UID}]++
+		case "end":
+			if k := (timer{e.Meta, e.UID}); open[k] > 0 {
+				open[k]--
+			}
+		}
+	}
+
+	if !started {
+		t.Errorf("hawkflowtest: expected Start for process %q, got none", process)
+		return false
+	}
+
+	ok := true
+	for k, n := range open {
+		if n > 0 {
+			t.Errorf("hawkflowtest: %d Start(s) for process %q with meta %q and uid %q were never ended", n, process, k.meta, k.uid)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// AssertMetric checks that a Metrics call for process reported item with value.
+func (rec *Recorder) AssertMetric(t testing.TB, process, item string, value float64) bool {
+	t.Helper()
+
+	var seen []string
+	for _, e := range rec.filter("metrics", process) {
+		v, ok := e.Items[item]
+		if !ok {
+			continue
+		}
+		if v == value {
+			return true
+		}
+		seen = append(seen, fmt.Sprint(v))
+	}
+
+	if len(seen) == 0 {
+		t.Errorf("hawkflowtest: expected metric %q = %v for process %q, got none", item, value, process)
+	} else {
+		t.Errorf("hawkflowtest: expected metric %q = %v for process %q, got %s", item, value, process, strings.Join(seen, ", "))
+	}
+	return false
+}
+
+// AssertException checks that an Exception was sent for process whose
+// message satisfies every matcher.
+func (rec *Recorder) AssertException(t testing.TB, process string, matchers ...ExceptionMatcher) bool {
+	t.Helper()
+
+	exceptions := rec.filter("exception", process)
+	if len(exceptions) == 0 {
+		t.Errorf("hawkflowtest: expected Exception for process %q, got none", process)
+		return false
+	}
+
+	for _, e := range exceptions {
+		if matchAll(e.Exception, matchers) {
+			return true
+		}
+	}
+
+	desc := make([]string, len(matchers))
+	for i, m := range matchers {
+		desc[i] = m.desc
+	}
+	t.Errorf("hawkflowtest: no Exception for process %q %s", process, strings.Join(desc, " and "))
+	return false
+}
+
+// AssertNoException checks that no Exception was sent for process.
+func (rec *Recorder) AssertNoException(t testing.TB, process string) bool {
+	t.Helper()
+	if n := len(rec.filter("exception", process)); n > 0 {
+		t.Errorf("hawkflowtest: expected no Exception for process %q, got %d", process, n)
+		return false
+	}
+	return true
+}
+
+// ExceptionMatcher matches the message of a recorded exception.
+type ExceptionMatcher struct {
+	desc  string
+	match func(string) bool
+}
+
+func ExceptionEquals(message string) ExceptionMatcher {
+	return ExceptionMatcher{
+		desc:  fmt.Sprintf("equal to %q", message),
+		match: func(s string) bool { return s == message },
+	}
+}
+
+func ExceptionContains(substr string) ExceptionMatcher {
+	return ExceptionMatcher{
+		desc:  fmt.Sprintf("containing %q", substr),
+		match: func(s string) bool { return strings.Contains(s, substr) },
+	}
+}
+
+// ExceptionMatches panics if pattern is not a valid regular expression.
+func ExceptionMatches(pattern string) ExceptionMatcher {
+	re := regexp.MustCompile(pattern)
+	return ExceptionMatcher{
+		desc:  fmt.Sprintf("matching %q", pattern),
+		match: re.MatchString,
+	}
+}
+
+// readBody reads the request body, decompressing gzip-encoded bodies.
+func readBody(req *http.Request) ([]byte, error) {
+	switch enc := req.Header.Get("content-encoding"); enc {
+	case "":
+		return io.ReadAll(req.Body)
+	case "gzip":
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %s", enc)
+	}
+}
+
+func matchAll(message string, matchers []ExceptionMatcher) bool {
+	for _, m := range matchers {
+		if !m.match(message) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package hawkflowtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hawkflow/hawkflow-go"
+)
+
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorderEvents(t *testing.T) {
+	rec := NewRecorder()
+	hf := hawkflow.New("api_key", hawkflow.OptionHTTPClient(rec))
+
+	_ = hf.Start("test_process", "test_meta", "test_uid")
+	_ = hf.Metrics("test_process", "", map[string]float64{"rows": 12})
+	_ = hf.Exception("test_process", "", "test exception message")
+	_ = hf.End("test_process", "test_meta", "test_uid")
+
+	events := rec.Events()
+	expected := []string{"start", "metrics", "exception", "end"}
+	if len(events) != len(expected) {
+		t.Fatalf("%v events expected, got %v", len(expected), len(events))
+	}
+	for i, kind := range expected {
+		if events[i].Kind != kind {
+			t.Errorf("%v expected, got %v", kind, events[i].Kind)
+		}
+	}
+	if events[0].Meta != "test_meta" || events[0].UID != "test_uid" {
+		t.Errorf("meta and uid were not recorded: %+v", events[0])
+	}
+
+	rec.Reset()
+	if len(rec.Events()) != 0 {
+		t.Errorf("Reset did not discard events.")
+	}
+}
+
+func TestRecorderStatusCode(t *testing.T) {
+	rec := NewRecorder()
+	rec.StatusCode = 500
+	hf := hawkflow.New("api_key", hawkflow.OptionHTTPClient(rec), hawkflow.OptionMaxRetries(2))
+
+	if err := hf.Start("test_process", "", ""); err == nil {
+		t.Errorf("error expected, got nil")
+	}
+	if n := len(rec.Events()); n != 2 {
+		t.Errorf("%v expected, got %v", 2, n)
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	rec := NewRecorder()
+	hf := hawkflow.New("api_key", hawkflow.OptionHTTPClient(rec))
+	_ = hf.Start("closed", "", "a")
+	_ = hf.Start("open", "", "a")
+	_ = hf.Start("open", "", "b")
+	_ = hf.End("closed", "", "a")
+	_ = hf.End("open", "", "a")
+	_ = hf.Metrics("closed", "", map[string]float64{"rows": 12})
+	_ = hf.Exception("closed", "", "ValueError: invalid row 7")
+
+	assertions := map[string]struct {
+		assert func(t testing.TB) bool
+		ok     bool
+	}{
+		"Started":                 {func(t testing.TB) bool { return rec.AssertStarted(t, "closed") }, true},
+		"Not started":             {func(t testing.TB) bool { return rec.AssertStarted(t, "missing") }, false},
+		"Ended":                   {func(t testing.TB) bool { return rec.AssertEnded(t, "open") }, true},
+		"Not ended":               {func(t testing.TB) bool { return rec.AssertEnded(t, "missing") }, false},
+		"Timer closed":            {func(t testing.TB) bool { return rec.AssertTimerClosed(t, "closed") }, true},
+		"Timer left open":         {func(t testing.TB) bool { return rec.AssertTimerClosed(t, "open") }, false},
+		"Timer never started":     {func(t testing.TB) bool { return rec.AssertTimerClosed(t, "missing") }, false},
+		"Metric":                  {func(t testing.TB) bool { return rec.AssertMetric(t, "closed", "rows", 12) }, true},
+		"Metric with wrong value": {func(t testing.TB) bool { return rec.AssertMetric(t, "closed", "rows", 13) }, false},
+		"Metric missing":          {func(t testing.TB) bool { return rec.AssertMetric(t, "closed", "cols", 12) }, false},
+		"Exception":               {func(t testing.TB) bool { return rec.AssertException(t, "closed") }, true},
+		"Exception equals": {func(t testing.TB) bool {
+			return rec.AssertException(t, "closed", ExceptionEquals("ValueError: invalid row 7"))
+		}, true},
+		"Exception contains":         {func(t testing.TB) bool { return rec.AssertException(t, "closed", ExceptionContains("invalid row")) }, true},
+		"Exception matches":          {func(t testing.TB) bool { return rec.AssertException(t, "closed", ExceptionMatches(`row \d+$`)) }, true},
+		"Exception does not match":   {func(t testing.TB) bool { return rec.AssertException(t, "closed", ExceptionContains("KeyError")) }, false},
+		"Exception missing":          {func(t testing.TB) bool { return rec.AssertException(t, "open") }, false},
+		"No exception":               {func(t testing.TB) bool { return rec.AssertNoException(t, "open") }, true},
+		"No exception when reported": {func(t testing.TB) bool { return rec.AssertNoException(t, "closed") }, false},
+	}
+
+	for name, testCase := range assertions {
+		t.Run(name, func(t *testing.T) {
+			ft := &fakeT{TB: t}
+			ok := testCase.assert(ft)
+
+			if ok != testCase.ok {
+				t.Errorf("%v expected, got %v", testCase.ok, ok)
+			}
+			if ok == (len(ft.errors) > 0) {
+				t.Errorf("reported errors %v do not match result %v", ft.errors, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package hawkflowtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hawkflow/hawkflow-go"
+)
+
+var allowedChars = regexp.MustCompile(`^[a-zA-Z\d\s_-]*$`)
+
+// Fault describes how the Server misbehaves for a single request.
+type Fault struct {
+	// Latency delays the response.
+	Latency time.Duration
+	// StatusCode replaces the normal response when non-zero.
+	StatusCode int
+	// RetryAfter sets the Retry-After header, in whole seconds.
+	RetryAfter time.Duration
+	// Reset closes the connection without writing a response.
+	Reset bool
+	// ResponseLost accepts the event, then closes the connection without
+	// writing a response, like a timeout after the event reached the API.
+	ResponseLost bool
+}
+
+func FaultLatency(d time.Duration) Fault {
+	return Fault{Latency: d}
+}
+
+func FaultStatus(code int) Fault {
+	return Fault{StatusCode: code}
+}
+
+// FaultRateLimited answers 429 Too Many Requests with a Retry-After header.
+func FaultRateLimited(retryAfter time.Duration) Fault {
+	return Fault{StatusCode: http.StatusTooManyRequests, RetryAfter: retryAfter}
+}
+
+func FaultUnauthorized() Fault {
+	return Fault{StatusCode: http.StatusUnauthorized}
+}
+
+func FaultReset() Fault {
+	return Fault{Reset: true}
+}
+
+func FaultResponseLost() Fault {
+	return Fault{ResponseLost: true}
+}
+
+// Repeat returns n copies of f, e.g. Repeat(FaultStatus(503), 3).
+func Repeat(f Fault, n int) []Fault {
+	faults := make([]Fault, n)
+	for i := range faults {
+		faults[i] = f
+	}
+	return faults
+}
+
+// Run is a Start paired with the End that closed it. Start and End are the
+// event timestamps sent by the client, or the arrival times without them, and
+// Duration is the client-measured duration if the End carried one.
+type Run struct {
+	Process   string
+	Meta      string
+	UID       string
+	ParentUID string
+	Path      string
+	Failed    bool
+	Start     time.Time
+	End       time.Time
+	Duration  time.Duration
+}
+
+type runKey struct{ process, meta, uid string }
+
+// Server is a local fake of the HawkFlow API for integration tests. It serves
+// start, end, exception and metrics under /v1/, checks the
+// x-hawkflow-api-key header, validates events like the real API, drops
+// events whose Idempotency-Key it has already seen and pairs every Start with
+// its End into a Run.
+type Server struct {
+	*httptest.Server
+
+	apiKey string
+
+	mu         sync.Mutex
+	limits     hawkflow.LimitSet
+	requests   int
+	events     []Event
+	runs       []Run
+	open       map[runKey][]time.Time
+	keys       map[string]bool
+	duplicates int
+	latency    time.Duration
+	faults     []Fault
+}
+
+// NewServer starts a Server that accepts requests authenticated with apiKey.
+// Call Close when done.
+func NewServer(apiKey string) *Server {
+	s := &Server{
+		apiKey: apiKey,
+		limits: hawkflow.DefaultLimits(),
+		open:   make(map[runKey][]time.Time),
+		keys:   make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	for _, kind := range []string{"start", "end", "exception", "metrics"} {
+		mux.Handle("/v1/"+kind, s.handler(kind))
+	}
+	mux.HandleFunc("/v1/limits", s.serveLimits)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Endpoint returns the value to pass to hawkflow.OptionEndpoint.
+func (s *Server) Endpoint() string {
+	return s.URL + "/v1/"
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	s.latency = d
+	s.mu.Unlock()
+}
+
+// SetLimits changes the limits the Server validates against and advertises
+// at /v1/limits.
+func (s *Server) SetLimits(l hawkflow.LimitSet) {
+	s.mu.Lock()
+	s.limits = l
+	s.mu.Unlock()
+}
+
+// Inject queues faults. Each request consumes the next queued fault, so
+// Inject(FaultStatus(500), FaultStatus(503)) fails the next two requests.
+func (s *Server) Inject(faults ...Fault) {
+	s.mu.Lock()
+	s.faults = append(s.faults, faults...)
+	s.mu.Unlock()
+}
+
+// Requests returns the number of requests received, including failed ones.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Duplicates returns how many events were dropped because their
+// Idempotency-Key had been seen before.
+func (s *Server) Duplicates() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.duplicates
+}
+
+// Events returns every accepted event in the order received.
+func (s *Server) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]Event, len(s.events))
+	copy(events, s.events)
+	return events
+}
+
+// Runs returns every completed Start/End pair in the order they ended.
+func (s *Server) Runs() []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := make([]Run, len(s.runs))
+	copy(runs, s.runs)
+	return runs
+}
+
+func (s *Server) nextFault() Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	f := Fault{Latency: s.latency}
+	if len(s.faults) > 0 {
+		f = s.faults[0]
+		f.Latency += s.latency
+		s.faults = s.faults[1:]
+	}
+	return f
+}
+
+func (s *Server) handler(kind string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f := s.nextFault()
+
+		if f.Latency > 0 {
+			select {
+			case <-time.After(f.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if f.Reset {
+			resetConnection(w)
+			return
+		}
+
+		if f.StatusCode != 0 {
+			if f.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(f.RetryAfter/time.Second)))
+			}
+			respond(w, f.StatusCode, http.StatusText(f.StatusCode))
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			respond(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+			return
+		}
+
+		if key := r.Header.Get("x-hawkflow-api-key"); key == "" || key != s.apiKey {
+			respond(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		body, err := readBody(r)
+		if err != nil {
+			respond(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		e := Event{Kind: kind}
+		if err := json.Unmarshal(body, &e); err != nil {
+			respond(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.mu.Lock()
+		limits := s.limits
+		s.mu.Unlock()
+		if err := validate(e, limits); err != nil {
+			respond(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		if !s.record(e, r.Header.Get("idempotency-key"), time.Now()) {
+			respond(w, http.StatusCreated, "duplicate")
+			return
+		}
+		if f.ResponseLost {
+			resetConnection(w)
+			return
+		}
+		respond(w, http.StatusCreated, "created")
+	})
+}
+
+func (s *Server) serveLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respond(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+	if key := r.Header.Get("x-hawkflow-api-key"); key == "" || key != s.apiKey {
+		respond(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	s.mu.Lock()
+	doc := hawkflow.LimitsDocument{SchemaVersion: 1, Limits: s.limits}
+	s.mu.Unlock()
+
+	w.Header().Set("content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+// record stores e unless key was seen before, reporting whether it did.
+func (s *Server) record(e Event, key string, received time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key != "" {
+		if s.keys[key] {
+			s.duplicates++
+			return false
+		}
+		s.keys[key] = true
+	}
+	s.events = append(s.events, e)
+
+	if t, err := time.Parse(time.RFC3339Nano, e.Timestamp); err == nil {
+		received = t
+	}
+
+	k := runKey{e.Process, e.Meta, e.UID}
+	switch e.Kind {
+	case "start":
+		s.open[k] = append(s.open[k], received)
+	case "end":
+		starts := s.open[k]
+		if len(starts) == 0 {
+			return true
+		}
+		s.open[k] = starts[1:]
+		duration := received.Sub(starts[0])
+		if e.DurationMs > 0 {
+			duration = time.Duration(e.DurationMs * float64(time.Millisecond))
+		}
+		s.runs = append(s.runs, Run{
+			Process:   e.Process,
+			Meta:      e.Meta,
+			UID:       e.UID,
+			ParentUID: e.ParentUID,
+			Path:      e.Path,
+			Failed:    e.Failed,
+			Start:     starts[0],
+			End:       received,
+			Duration:  duration,
+		})
+	}
+	return true
+}
+
+func respond(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"status":  strconv.Itoa(status),
+		"message": message,
+	})
+}
+
+// resetConnection drops the connection, sending a TCP RST where possible.
+func resetConnection(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic("hawkflowtest: response writer does not support hijacking")
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(err)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+// validate mirrors the field rules enforced by the HawkFlow API.
+func validate(e Event, l hawkflow.LimitSet) error {
+	if err := checkField("process", e.Process, l.Process); err != nil {
+		return err
+	}
+	if err := checkField("meta", e.Meta, l.Meta); err != nil {
+		return err
+	}
+	if err := checkField("uid", e.UID, l.UID); err != nil {
+		return err
+	}
+	if err := checkField("exception", e.Exception, l.Exception); err != nil {
+		return err
+	}
+	if err := checkField("parent_uid", e.ParentUID, hawkflow.FieldLimit{MaxLength: l.UID.MaxLength, Restricted: l.UID.Restricted}); err != nil {
+		return err
+	}
+
+	if e.Timestamp != "" {
+		if _, err := time.Parse(time.RFC3339Nano, e.Timestamp); err != nil {
+			return fmt.Errorf("timestamp must be RFC 3339")
+		}
+	}
+	if e.DurationMs < 0 {
+		return fmt.Errorf("duration_ms must not be negative")
+	}
+	if e.SampleRate < 0 || e.SampleRate > 1 {
+		return fmt.Errorf("sample_rate must be between 0 and 1")
+	}
+
+	if e.Kind == "metrics" {
+		if len(e.Items) == 0 && l.Items.Required {
+			return fmt.Errorf("items are required")
+		}
+		if l.Items.MaxLength > 0 && len(e.Items) > l.Items.MaxLength {
+			return fmt.Errorf("items exceed %d entries", l.Items.MaxLength)
+		}
+		for k := range e.Items {
+			if err := checkField("item key", k, l.ItemKey); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkField(name, value string, limit hawkflow.FieldLimit) error {
+	switch {
+	case value == "":
+		if limit.Required {
+			return fmt.Errorf("%s is required", name)
+		}
+	case limit.MaxLength > 0 && len(value) > limit.MaxLength:
+		return fmt.Errorf("%s exceeds %d characters", name, limit.MaxLength)
+	case limit.Restricted && !allowedChars.MatchString(value):
+		return fmt.Errorf("%s contains unsupported characters", name)
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+package hawkflowtest
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hawkflow/hawkflow-go"
+)
+
+func TestServerRuns(t *testing.T) {
+	s := NewServer("api_key")
+	defer s.Close()
+
+	hf := hawkflow.New("api_key", hawkflow.OptionEndpoint(s.Endpoint()))
+	if err := hf.Start("test_process", "test_meta", "test_uid"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := hf.End("test_process", "test_meta", "test_uid"); err != nil {
+		t.Fatal(err)
+	}
+	if err := hf.Metrics("test_process", "", map[string]float64{"rows": 12}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(s.Events()); n != 3 {
+		t.Errorf("%v expected, got %v", 3, n)
+	}
+	runs := s.Runs()
+	if len(runs) != 1 {
+		t.Fatalf("%v expected, got %v", 1, len(runs))
+	}
+	if runs[0].Process != "test_process" || runs[0].UID != "test_uid" {
+		t.Errorf("unexpected run %+v", runs[0])
+	}
+	if runs[0].Duration < 20*time.Millisecond {
+		t.Errorf("duration of at least 20ms expected, got %v", runs[0].Duration)
+	}
+}
+
+func TestServerRequests(t *testing.T) {
+	testCases := map[string]struct {
+		apiKey       string
+		method       string
+		path         string
+		body         string
+		expectedCode int
+	}{
+		"Valid request": {
+			apiKey:       "api_key",
+			method:       "POST",
+			path:         "/v1/start",
+			body:         `{"process":"test_process"}`,
+			expectedCode: 201,
+		},
+		"Missing API key": {
+			method:       "POST",
+			path:         "/v1/start",
+			body:         `{"process":"test_process"}`,
+			expectedCode: 401,
+		},
+		"Wrong API key": {
+			apiKey:       "other_key",
+			method:       "POST",
+			path:         "/v1/start",
+			body:         `{"process":"test_process"}`,
+			expectedCode: 401,
+		},
+		"Wrong method": {
+			apiKey:       "api_key",
+			method:       "GET",
+			path:         "/v1/start",
+			expectedCode: 405,
+		},
+		"Unknown path": {
+			apiKey:       "api_key",
+			method:       "POST",
+			path:         "/v1/unknown",
+			body:         `{"process":"test_process"}`,
+			expectedCode: 404,
+		},
+		"Invalid process": {
+			apiKey:       "api_key",
+			method:       "POST",
+			path:         "/v1/start",
+			body:         `{"process":"invalid process ❌"}`,
+			expectedCode: 422,
+		},
+		"Missing metrics items": {
+			apiKey:       "api_key",
+			method:       "POST",
+			path:         "/v1/metrics",
+			body:         `{"process":"test_process"}`,
+			expectedCode: 422,
+		},
+		"Malformed body": {
+			apiKey:       "api_key",
+			method:       "POST",
+			path:         "/v1/end",
+			body:         `{"process":`,
+			expectedCode: 400,
+		},
+	}
+
+	s := NewServer("api_key")
+	defer s.Close()
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest(testCase.method, s.URL+testCase.path, strings.NewReader(testCase.body))
+			if testCase.apiKey != "" {
+				req.Header.Set("x-hawkflow-api-key", testCase.apiKey)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != testCase.expectedCode {
+				t.Errorf("%v expected, got %v", testCase.expectedCode, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestServerFaults(t *testing.T) {
+	testCases := map[string]struct {
+		faults           []Fault
+		maxRetries       uint8
+		timeout          time.Duration
+		expectedRequests int
+		expectedEvents   int
+		error            bool
+	}{
+		"5xx sequence recovered by retries": {
+			faults:           []Fault{FaultStatus(500), FaultStatus(503)},
+			maxRetries:       3,
+			expectedRequests: 3,
+			expectedEvents:   1,
+		},
+		"5xx sequence exhausts retries": {
+			faults:           Repeat(FaultStatus(502), 3),
+			maxRetries:       3,
+			expectedRequests: 3,
+			error:            true,
+		},
+		"Unauthorized is not retried": {
+			faults:           []Fault{FaultUnauthorized()},
+			maxRetries:       3,
+			expectedRequests: 1,
+			error:            true,
+		},
+		"Rate limited then accepted": {
+			faults:           []Fault{FaultRateLimited(time.Second)},
+			maxRetries:       3,
+			expectedRequests: 2,
+			expectedEvents:   1,
+		},
+		"Connection reset then accepted": {
+			faults:           []Fault{FaultReset()},
+			maxRetries:       3,
+			expectedRequests: 2,
+			expectedEvents:   1,
+		},
+		"Latency beyond client timeout": {
+			faults:           []Fault{FaultLatency(200 * time.Millisecond)},
+			maxRetries:       1,
+			timeout:          50 * time.Millisecond,
+			expectedRequests: 1,
+			error:            true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s := NewServer("api_key")
+			defer s.Close()
+			s.Inject(testCase.faults...)
+
+			timeout := testCase.timeout
+			if timeout == 0 {
+				timeout = time.Second
+			}
+			hf := hawkflow.New("api_key",
+				hawkflow.OptionEndpoint(s.Endpoint()),
+				hawkflow.OptionMaxRetries(testCase.maxRetries),
+				hawkflow.OptionTimeout(timeout),
+			)
+			err := hf.Start("test_process", "", "")
+
+			if (err != nil) != testCase.error {
+				t.Errorf("error %v expected, got %v", testCase.error, err)
+			}
+			if n := s.Requests(); n != testCase.expectedRequests {
+				t.Errorf("%v requests expected, got %v", testCase.expectedRequests, n)
+			}
+			if n := len(s.Events()); n != testCase.expectedEvents {
+				t.Errorf("%v events expected, got %v", testCase.expectedEvents, n)
+			}
+		})
+	}
+}
+
+func TestServerRetryAfterHeader(t *testing.T) {
+	s := NewServer("api_key")
+	defer s.Close()
+	s.Inject(FaultRateLimited(30 * time.Second))
+
+	req, _ := http.NewRequest("POST", s.Endpoint()+"start", strings.NewReader(`{"process":"test_process"}`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 429 {
+		t.Errorf("%v expected, got %v", 429, resp.StatusCode)
+	}
+	if v := resp.Header.Get("Retry-After"); v != "30" {
+		t.Errorf("%v expected, got %v", "30", v)
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	s := NewServer("api_key")
+	defer s.Close()
+
+	limits := hawkflow.DefaultLimits()
+	limits.Process.MaxLength = 10
+	s.SetLimits(limits)
+
+	hf := hawkflow.New("api_key", hawkflow.OptionEndpoint(s.Endpoint()), hawkflow.OptionRemoteLimits(""))
+	if n := hf.Limits().Process.MaxLength; n != 10 {
+		t.Errorf("%v expected, got %v", 10, n)
+	}
+
+	// A client with the built-in limits is rejected by the server.
+	hf = hawkflow.New("api_key", hawkflow.OptionEndpoint(s.Endpoint()), hawkflow.OptionMaxRetries(1))
+	if err := hf.Start("process_name_too_long", "", ""); err == nil {
+		t.Errorf("error expected, got nil")
+	}
+	if n := len(s.Events()); n != 0 {
+		t.Errorf("%v expected, got %v", 0, n)
+	}
+}
+
+func TestServerCompressedRequests(t *testing.T) {
+	s := NewServer("api_key")
+	defer s.Close()
+
+	hf := hawkflow.New("api_key",
+		hawkflow.OptionEndpoint(s.Endpoint()),
+		hawkflow.OptionCompression(hawkflow.GzipCompressor(gzip.DefaultCompression), 0),
+	)
+	if err := hf.Exception("test_process", "", "test exception message"); err != nil {
+		t.Fatal(err)
+	}
+
+	events := s.Events()
+	if len(events) != 1 || events[0].Exception != "test exception message" {
+		t.Errorf("decompressed exception expected, got %+v", events)
+	}
+}
+
+func TestServerIdempotencyKeys(t *testing.T) {
+	s := NewServer("api_key")
+	defer s.Close()
+	s.Inject(FaultResponseLost())
+
+	hf := hawkflow.New("api_key", hawkflow.OptionEndpoint(s.Endpoint()), hawkflow.OptionMaxRetries(3))
+	if err := hf.Start("test_process", "", "test_uid"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := s.Requests(); n != 2 {
+		t.Errorf("%v requests expected, got %v", 2, n)
+	}
+	if n := len(s.Events()); n != 1 {
+		t.Errorf("%v events expected, got %v", 1, n)
+	}
+	if n := s.Duplicates(); n != 1 {
+		t.Errorf("%v duplicates expected, got %v", 1, n)
+	}
+
+	// A new event gets a new key.
+	if err := hf.End("test_process", "", "test_uid"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.Events()); n != 2 {
+		t.Errorf("%v events expected, got %v", 2, n)
+	}
+}
+
+func TestServerClientDurations(t *testing.T) {
+	s := NewServer("api_key")
+	defer s.Close()
+
+	// Latency on the End must not inflate the duration.
+	hf := hawkflow.New("api_key", hawkflow.OptionEndpoint(s.Endpoint()), hawkflow.OptionTimestamps(true))
+	timer, err := hf.StartTimer("test_process", "", "test_uid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Inject(FaultLatency(100 * time.Millisecond))
+	if err := timer.End(); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := s.Runs()
+	if len(runs) != 1 {
+		t.Fatalf("%v expected, got %v", 1, len(runs))
+	}
+	if runs[0].Duration >= 100*time.Millisecond {
+		t.Errorf("client-measured duration expected, got %v", runs[0].Duration)
+	}
+	if events := s.Events(); events[1].DurationMs == 0 || events[1].Timestamp == "" {
+		t.Errorf("duration and timestamp expected, got %+v", events[1])
+	}
+}
+
+func TestServerBackfilledRuns(t *testing.T) {
+	s := NewServer("api_key")
+	defer s.Close()
+
+	start := time.Date(2023, 5, 1, 2, 0, 0, 0, time.UTC)
+	hf := hawkflow.New("api_key", hawkflow.OptionEndpoint(s.Endpoint()))
+	err := hf.Backfill(hawkflow.Run{Process: "nightly_export", UID: "run_1", Start: start, End: start.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs := s.Runs()
+	if len(runs) != 1 {
+		t.Fatalf("%v expected, got %v", 1, len(runs))
+	}
+	if !runs[0].Start.Equal(start) || runs[0].Duration != time.Minute {
+		t.Errorf("historical run expected, got %+v", runs[0])
+	}
+}
+
+func TestServerNestedRuns(t *testing.T) {
+	s := NewServer("api_key")
+	defer s.Close()
+
+	hf := hawkflow.New("api_key", hawkflow.OptionEndpoint(s.Endpoint()))
+	job, _ := hf.StartTimer("nightly_export", "", "")
+	stage, _ := job.StartChild("load", "")
+	_ = stage.Exception("bad row")
+	_ = stage.End()
+	_ = job.End()
+
+	runs := s.Runs()
+	if len(runs) != 2 {
+		t.Fatalf("%v expected, got %v", 2, len(runs))
+	}
+	if runs[0].ParentUID != job.UID() || runs[0].Path != "nightly_export/load" || !runs[0].Failed {
+		t.Errorf("failed child run expected, got %+v", runs[0])
+	}
+	if runs[1].ParentUID != "" || !runs[1].Failed {
+		t.Errorf("failed parent run expected, got %+v", runs[1])
+	}
+}
+
+func TestServerBackfillTwice(t *testing.T) {
+	s := NewServer("api_key")
+	defer s.Close()
+
+	input := `process,uid,start,end,exception,metrics.rows
+nightly_export,run_1,2023-05-01T02:00:00Z,2023-05-01T02:01:30Z,disk full,12
+`
+	hf := hawkflow.New("api_key", hawkflow.OptionEndpoint(s.Endpoint()))
+	for i := 0; i < 2; i++ {
+		if _, err := hf.Import(strings.NewReader(input), hawkflow.ImportCSV); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(s.Events()); n != 4 {
+		t.Errorf("%v events expected, got %v", 4, n)
+	}
+	if n := s.Duplicates(); n != 4 {
+		t.Errorf("%v duplicates expected, got %v", 4, n)
+	}
+	if n := len(s.Runs()); n != 1 {
+		t.Errorf("%v runs expected, got %v", 1, n)
+	}
+}
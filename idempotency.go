@@ -0,0 +1,36 @@
+package hawkflow
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var fallbackKeys uint64
+
+// newIdempotencyKey returns a random key sent in the Idempotency-Key header
+// of every attempt of an event, so the API can drop duplicates caused by
+// retrying a request that was received but whose response was lost.
+func newIdempotencyKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// Unique within the process, which is still enough for retries.
+		return strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatUint(atomic.AddUint64(&fallbackKeys, 1), 36)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// runIdempotencyKey derives the key of an event of a backfilled run from the
+// run and the event's path, so submitting the same run again, e.g. by
+// re-running an import, sends the same keys and the API drops the duplicates.
+func runIdempotencyKey(r *request, start time.Time, path string) string {
+	h := sha256.New()
+	for _, s := range []string{r.Process, r.Meta, r.UID, r.ParentUID, formatTimestamp(start), path} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,57 @@
+package hawkflow
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// keyRecorder records the Idempotency-Key of every attempt.
+type keyRecorder struct {
+	statusCodes []int
+	keys        []string
+}
+
+func (k *keyRecorder) Do(req *http.Request) (*http.Response, error) {
+	k.keys = append(k.keys, req.Header.Get("idempotency-key"))
+	status := 201
+	if len(k.statusCodes) > 0 {
+		status, k.statusCodes = k.statusCodes[0], k.statusCodes[1:]
+	}
+	return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+}
+
+func TestIdempotencyKeyReusedOnRetry(t *testing.T) {
+	rec := &keyRecorder{statusCodes: []int{500, 504}}
+	hfc := New("api_key", OptionHTTPClient(rec))
+
+	_ = hfc.Start("test_process", "", "")
+	_ = hfc.End("test_process", "", "")
+
+	if len(rec.keys) != 4 {
+		t.Fatalf("%v expected, got %v", 4, len(rec.keys))
+	}
+	if rec.keys[0] == "" || rec.keys[0] != rec.keys[1] || rec.keys[1] != rec.keys[2] {
+		t.Errorf("same key on every attempt expected, got %v", rec.keys)
+	}
+	if rec.keys[3] == rec.keys[0] {
+		t.Errorf("new key for a new event expected, got %v", rec.keys)
+	}
+}
+
+func TestIdempotencyKeyReusedOnReplay(t *testing.T) {
+	log := new(bytes.Buffer)
+	hfc := New("api_key", OptionHTTPClient(&keyRecorder{statusCodes: []int{503, 503, 503}}), OptionTrafficLog(log))
+	_ = hfc.Start("test_process", "", "")
+
+	rec := &keyRecorder{}
+	hfc = New("api_key", OptionHTTPClient(rec))
+	if _, err := hfc.Replay(strings.NewReader(log.String())); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.keys) != 1 || !strings.Contains(log.String(), `"idempotency-key":"`+rec.keys[0]+`"`) {
+		t.Errorf("recorded key expected, got %v", rec.keys)
+	}
+}
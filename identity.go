@@ -0,0 +1,94 @@
+package hawkflow
+
+import (
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+type identity struct {
+	userAgentSuffix string
+	host            string
+	service         string
+	environment     string
+}
+
+// OptionUserAgent appends suffix, e.g. "billing-worker/2.3.1", to the
+// User-Agent header.
+func OptionUserAgent(suffix string) func(*client) {
+	return func(hfc *client) { hfc.identity.userAgentSuffix = cleanHeader(suffix) }
+}
+
+// OptionHost sends host in the x-hawkflow-host header. An empty host uses
+// the machine's hostname.
+func OptionHost(host string) func(*client) {
+	return func(hfc *client) {
+		if host == "" {
+			host, _ = os.Hostname()
+		}
+		hfc.identity.host = cleanHeader(host)
+	}
+}
+
+// OptionService sends service in the x-hawkflow-service header.
+func OptionService(service string) func(*client) {
+	return func(hfc *client) { hfc.identity.service = cleanHeader(service) }
+}
+
+// OptionEnvironment sends environment, e.g. "staging", in the
+// x-hawkflow-environment header.
+func OptionEnvironment(environment string) func(*client) {
+	return func(hfc *client) { hfc.identity.environment = cleanHeader(environment) }
+}
+
+// userAgent returns e.g. "hawkflow-go/1.1.0 go/go1.17.13 linux/amd64".
+func userAgent(suffix string) string {
+	ua := "hawkflow-go/" + Version + " go/" + runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH
+	if suffix != "" {
+		ua += " " + suffix
+	}
+	return ua
+}
+
+// buildHeaders prepares the headers sent with every API request.
+func (hfc *client) buildHeaders() {
+	h := http.Header{}
+	h.Set("user-agent", userAgent(hfc.identity.userAgentSuffix))
+	h.Set("x-hawkflow-api-key", hfc.apiKey)
+	h.Set("x-hawkflow-sdk-version", Version)
+	h.Set("x-hawkflow-schema-version", strconv.Itoa(_SCHEMA_VERSION))
+
+	if hfc.identity.host != "" {
+		h.Set("x-hawkflow-host", hfc.identity.host)
+	}
+	if hfc.identity.service != "" {
+		h.Set("x-hawkflow-service", hfc.identity.service)
+	}
+	if hfc.identity.environment != "" {
+		h.Set("x-hawkflow-environment", hfc.identity.environment)
+	}
+
+	hfc.headers = h
+}
+
+// header returns a copy of the client's headers with room for extra more.
+// The values are shared, so they must not be modified in place.
+func (hfc *client) header(extra int) http.Header {
+	h := make(http.Header, len(hfc.headers)+extra)
+	for k, v := range hfc.headers {
+		h[k] = v
+	}
+	return h
+}
+
+// cleanHeader drops characters that are not allowed in header values.
+func cleanHeader(s string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s))
+}
@@ -0,0 +1,75 @@
+package hawkflow
+
+import (
+	"os"
+	"regexp"
+	"runtime"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	if !regexp.MustCompile(`^\d+\.\d+\.\d+$`).MatchString(Version) {
+		t.Errorf("semantic version expected, got %v", Version)
+	}
+}
+
+func TestIdentityHeaders(t *testing.T) {
+	hostname, _ := os.Hostname()
+	base := "hawkflow-go/" + Version + " go/" + runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH
+
+	testCases := map[string]struct {
+		options  []option
+		expected map[string]string
+	}{
+		"Default headers": {
+			expected: map[string]string{
+				"User-Agent":             base,
+				"X-Hawkflow-Sdk-Version": Version,
+				"X-Hawkflow-Host":        "",
+				"X-Hawkflow-Service":     "",
+				"X-Hawkflow-Environment": "",
+			},
+		},
+		"User agent suffix": {
+			options: []option{OptionUserAgent("billing-worker/2.3.1")},
+			expected: map[string]string{
+				"User-Agent": base + " billing-worker/2.3.1",
+			},
+		},
+		"Identification headers": {
+			options: []option{OptionHost("worker-1"), OptionService("billing"), OptionEnvironment("staging")},
+			expected: map[string]string{
+				"X-Hawkflow-Host":        "worker-1",
+				"X-Hawkflow-Service":     "billing",
+				"X-Hawkflow-Environment": "staging",
+			},
+		},
+		"Hostname": {
+			options: []option{OptionHost("")},
+			expected: map[string]string{
+				"X-Hawkflow-Host": hostname,
+			},
+		},
+		"Control characters are dropped": {
+			options: []option{OptionService("billing\r\nx-injected: 1")},
+			expected: map[string]string{
+				"X-Hawkflow-Service": "billingx-injected: 1",
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := &ClientMock{returnStatusCode: 201}
+			options := append([]option{OptionHTTPClient(c)}, testCase.options...)
+			hfc := New("api_key", options...)
+			_ = hfc.Start("test_process", "", "")
+
+			for k, v := range testCase.expected {
+				if c.request.Header.Get(k) != v {
+					t.Errorf("%v: %v expected, got %v", k, v, c.request.Header.Get(k))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package hawkflow
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportFormat is the file format read by Import.
+type ImportFormat int
+
+const (
+	// ImportCSV reads a CSV file with a header row naming the columns
+	// process, start and end, and optionally meta, uid, parent_uid, exception
+	// and one "metrics.<key>" column per metric. Times are RFC 3339; empty
+	// metric cells are left out.
+	ImportCSV ImportFormat = iota
+	// ImportJSONL reads one JSON encoded Run per line.
+	ImportJSONL
+)
+
+// RowError is a row Import could not submit.
+type RowError struct {
+	// Row is the line number in the file.
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ImportResult reports the outcome of Import.
+type ImportResult struct {
+	// Imported counts the runs submitted.
+	Imported int
+	// Errors lists every row that was invalid or failed to send.
+	Errors []*RowError
+}
+
+// Import submits every run in r with Backfill. Invalid rows and rows that
+// fail to send are reported in the result and skipped; the error is only set
+// if r cannot be read at all.
+func (hfc *client) Import(r io.Reader, format ImportFormat) (ImportResult, error) {
+	var result ImportResult
+	submit := func(row int, run Run, err error) {
+		if err == nil {
+			err = hfc.Backfill(run)
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, &RowError{Row: row, Err: err})
+			return
+		}
+		result.Imported++
+	}
+
+	var err error
+	switch format {
+	case ImportCSV:
+		err = readRunsCSV(r, submit)
+	case ImportJSONL:
+		err = readRunsJSONL(r, submit)
+	default:
+		err = createError(fmt.Sprintf("Unknown import format %d.", format))
+	}
+	return result, err
+}
+
+func readRunsJSONL(r io.Reader, submit func(int, Run, error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var run Run
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.DisallowUnknownFields()
+		submit(line, run, dec.Decode(&run))
+	}
+	return scanner.Err()
+}
+
+func readRunsCSV(r io.Reader, submit func(int, Run, error)) error {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("reading CSV header: %w", err)
+	}
+	columns, err := runColumns(header)
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			submit(parseErr.Line, Run{}, parseErr.Err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := cr.FieldPos(0)
+		run, err := parseRunRecord(columns, record)
+		submit(line, run, err)
+	}
+}
+
+func runColumns(header []string) ([]string, error) {
+	columns := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		switch {
+		case h == "process", h == "meta", h == "uid", h == "parent_uid", h == "start", h == "end", h == "exception":
+		case strings.HasPrefix(h, "metrics.") && len(h) > len("metrics."):
+			h = "metrics." + strings.TrimSpace(header[i])[len("metrics."):]
+		default:
+			return nil, createError(fmt.Sprintf("Unknown CSV column %q.", header[i]))
+		}
+		if seen[h] {
+			return nil, createError(fmt.Sprintf("Duplicate CSV column %q.", header[i]))
+		}
+		seen[h] = true
+		columns[i] = h
+	}
+	for _, required := range []string{"process", "start", "end"} {
+		if !seen[required] {
+			return nil, createError(fmt.Sprintf("Missing CSV column %q.", required))
+		}
+	}
+	return columns, nil
+}
+
+func parseRunRecord(columns, record []string) (Run, error) {
+	var run Run
+	for i, value := range record {
+		var err error
+		switch c := columns[i]; c {
+		case "process":
+			run.Process = value
+		case "meta":
+			run.Meta = value
+		case "uid":
+			run.UID = value
+		case "parent_uid":
+			run.ParentUID = value
+		case "exception":
+			run.Exception = value
+		case "start":
+			run.Start, err = parseRunTime(c, value)
+		case "end":
+			run.End, err = parseRunTime(c, value)
+		default:
+			if value == "" {
+				continue
+			}
+			f, perr := strconv.ParseFloat(value, 64)
+			if perr != nil {
+				return run, createError(fmt.Sprintf("Invalid value %q for %s.", value, c))
+			}
+			if run.Metrics == nil {
+				run.Metrics = make(map[string]float64)
+			}
+			run.Metrics[strings.TrimPrefix(c, "metrics.")] = f
+		}
+		if err != nil {
+			return run, err
+		}
+	}
+	return run, nil
+}
+
+func parseRunTime(column, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return t, createError(fmt.Sprintf("Invalid %s time %q, expected RFC 3339.", column, value))
+	}
+	return t, nil
+}
@@ -0,0 +1,149 @@
+package hawkflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// _MIN_MAX_LENGTH is the smallest max_length accepted from the API for
+// process, uid and item_key.
+const _MIN_MAX_LENGTH = 10
+
+// LimitsDocument is the limits schema advertised by the API.
+type LimitsDocument struct {
+	SchemaVersion int      `json:"schema_version"`
+	Limits        LimitSet `json:"limits"`
+}
+
+// OptionRemoteLimits fetches the validation limits from the API when the
+// client is created. If that fails, or with OptionDryRun, the last document
+// saved to cacheFile is used, and failing that DefaultLimits. Pass an empty cacheFile to disable
+// caching.
+//
+// Only lengths and required flags are taken from the API; the allowed
+// character set is always the built-in one.
+func OptionRemoteLimits(cacheFile string) func(*client) {
+	return func(hfc *client) {
+		hfc.remoteLimits = true
+		hfc.limitsCache = cacheFile
+	}
+}
+
+// Limits returns the limits the client validates events against.
+func (hfc *client) Limits() LimitSet {
+	return hfc.limits
+}
+
+func (hfc *client) loadRemoteLimits() {
+	// A dry run never contacts the API.
+	if hfc.dryRun != nil {
+		hfc.log("Dry run, limits not fetched")
+	} else {
+		body, err := hfc.fetchLimits()
+		if err == nil {
+			var limits LimitSet
+			if limits, err = parseLimits(body); err == nil {
+				hfc.limits = limits
+				hfc.log("Using limits from API")
+				hfc.saveLimitsCache(body)
+				return
+			}
+		}
+		hfc.logf("Fetching limits failed: %s", err)
+	}
+
+	if hfc.limitsCache == "" {
+		return
+	}
+	body, err := os.ReadFile(hfc.limitsCache)
+	if err == nil {
+		var limits LimitSet
+		if limits, err = parseLimits(body); err == nil {
+			hfc.limits = limits
+			hfc.logf("Using cached limits from %s", hfc.limitsCache)
+			return
+		}
+	}
+	hfc.logf("Reading cached limits failed: %s", err)
+}
+
+func (hfc *client) fetchLimits() ([]byte, error) {
+	if err := validateApiKey(hfc.apiKey); err != nil {
+		return nil, err
+	}
+	if hfc.configErr != nil {
+		return nil, hfc.configErr
+	}
+
+	req, err := http.NewRequest("GET", hfc.endpoint+"limits", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = hfc.header(1)
+	req.Header.Set("accept", "application/json")
+
+	resp, err := hfc.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, _MAX_RESPONSE_SIZE))
+	if err != nil {
+		return nil, err
+	}
+
+	if http.StatusOK != resp.StatusCode {
+		return nil, errors.New(string(body))
+	}
+
+	return body, nil
+}
+
+func (hfc *client) saveLimitsCache(body []byte) {
+	if hfc.limitsCache == "" {
+		return
+	}
+	if err := os.WriteFile(hfc.limitsCache, body, 0o644); err != nil {
+		hfc.logf("Writing limits cache failed: %s", err)
+	}
+}
+
+// parseLimits decodes a LimitsDocument over the built-in limits, so fields the
+// document leaves out keep their defaults.
+func parseLimits(body []byte) (LimitSet, error) {
+	doc := LimitsDocument{Limits: defaultLimits}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return LimitSet{}, err
+	}
+
+	if doc.SchemaVersion < 1 || doc.SchemaVersion > _SCHEMA_VERSION {
+		return LimitSet{}, fmt.Errorf("unsupported limits schema version %d", doc.SchemaVersion)
+	}
+
+	l := &doc.Limits
+	for _, f := range []*FieldLimit{&l.APIKey, &l.Process, &l.Meta, &l.UID, &l.Exception, &l.Items, &l.ItemKey} {
+		if f.MaxLength < 0 {
+			return LimitSet{}, fmt.Errorf("invalid max length %d", f.MaxLength)
+		}
+	}
+	// Names and keys shorter than this could not be told apart.
+	for _, f := range []*FieldLimit{&l.Process, &l.UID, &l.ItemKey} {
+		if f.MaxLength > 0 && f.MaxLength < _MIN_MAX_LENGTH {
+			return LimitSet{}, fmt.Errorf("max length %d below minimum of %d", f.MaxLength, _MIN_MAX_LENGTH)
+		}
+	}
+
+	// The character set is not negotiable, see the retracted v1.0.3.
+	l.APIKey.Restricted = defaultLimits.APIKey.Restricted
+	l.Process.Restricted = defaultLimits.Process.Restricted
+	l.Meta.Restricted = defaultLimits.Meta.Restricted
+	l.UID.Restricted = defaultLimits.UID.Restricted
+	l.Exception.Restricted = defaultLimits.Exception.Restricted
+	l.ItemKey.Restricted = defaultLimits.ItemKey.Restricted
+
+	return doc.Limits, nil
+}
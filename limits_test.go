@@ -0,0 +1,175 @@
+package hawkflow
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOptionRemoteLimits(t *testing.T) {
+	testCases := map[string]struct {
+		statusCode        int
+		body              string
+		cache             string
+		expectedProcess   FieldLimit
+		expectedMeta      FieldLimit
+		expectedCacheFile string
+	}{
+		"Limits from API": {
+			statusCode:        200,
+			body:              `{"schema_version":1,"limits":{"process":{"max_length":10,"required":true}}}`,
+			expectedProcess:   FieldLimit{MaxLength: 10, Required: true, Restricted: true},
+			expectedMeta:      defaultLimits.Meta,
+			expectedCacheFile: `{"schema_version":1,"limits":{"process":{"max_length":10,"required":true}}}`,
+		},
+		"Character set cannot be relaxed": {
+			statusCode:        200,
+			body:              `{"schema_version":1,"limits":{"meta":{"max_length":20,"restricted":false}}}`,
+			expectedProcess:   defaultLimits.Process,
+			expectedMeta:      FieldLimit{MaxLength: 20, Restricted: true},
+			expectedCacheFile: `{"schema_version":1,"limits":{"meta":{"max_length":20,"restricted":false}}}`,
+		},
+		"Unsupported schema version": {
+			statusCode:      200,
+			body:            `{"schema_version":2,"limits":{"process":{"max_length":10}}}`,
+			expectedProcess: defaultLimits.Process,
+			expectedMeta:    defaultLimits.Meta,
+		},
+		"Invalid limits": {
+			statusCode:      200,
+			body:            `{"schema_version":1,"limits":{"process":{"max_length":-1}}}`,
+			expectedProcess: defaultLimits.Process,
+			expectedMeta:    defaultLimits.Meta,
+		},
+		"Limits below the minimum": {
+			statusCode:      200,
+			body:            `{"schema_version":1,"limits":{"uid":{"max_length":8}}}`,
+			expectedProcess: defaultLimits.Process,
+			expectedMeta:    defaultLimits.Meta,
+		},
+		"API error falls back to cache": {
+			statusCode:        500,
+			body:              `{"status":"500","message":"Server error"}`,
+			cache:             `{"schema_version":1,"limits":{"process":{"max_length":20,"required":true}}}`,
+			expectedProcess:   FieldLimit{MaxLength: 20, Required: true, Restricted: true},
+			expectedMeta:      defaultLimits.Meta,
+			expectedCacheFile: `{"schema_version":1,"limits":{"process":{"max_length":20,"required":true}}}`,
+		},
+		"API error without cache falls back to defaults": {
+			statusCode:      500,
+			body:            `{"status":"500","message":"Server error"}`,
+			expectedProcess: defaultLimits.Process,
+			expectedMeta:    defaultLimits.Meta,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cacheFile := filepath.Join(t.TempDir(), "limits.json")
+			if testCase.cache != "" {
+				if err := os.WriteFile(cacheFile, []byte(testCase.cache), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c := &ClientMock{returnStatusCode: testCase.statusCode, returnBody: testCase.body}
+			hfc := New("api_key", OptionHTTPClient(c), OptionRemoteLimits(cacheFile))
+			limits := hfc.Limits()
+
+			if c.count != 1 {
+				t.Errorf("%v expected, got %v", 1, c.count)
+			}
+			if limits.Process != testCase.expectedProcess {
+				t.Errorf("%v expected, got %v", testCase.expectedProcess, limits.Process)
+			}
+			if limits.Meta != testCase.expectedMeta {
+				t.Errorf("%v expected, got %v", testCase.expectedMeta, limits.Meta)
+			}
+			cached, _ := os.ReadFile(cacheFile)
+			if string(cached) != testCase.expectedCacheFile {
+				t.Errorf("%v expected, got %v", testCase.expectedCacheFile, string(cached))
+			}
+		})
+	}
+}
+
+func TestOptionRemoteLimitsRequest(t *testing.T) {
+	c := &ClientMock{returnStatusCode: 200, returnBody: `{"schema_version":1,"limits":{}}`}
+	New("api_key", OptionHTTPClient(c), OptionRemoteLimits(""))
+
+	if c.request.Method != "GET" {
+		t.Errorf("%v expected, got %v", "GET", c.request.Method)
+	}
+	if c.request.URL.String() != "https://api.hawkflow.ai/v1/limits" {
+		t.Errorf("%v expected, got %v", "https://api.hawkflow.ai/v1/limits", c.request.URL.String())
+	}
+	if v := c.request.Header.Get("x-hawkflow-schema-version"); v != "1" {
+		t.Errorf("%v expected, got %v", "1", v)
+	}
+	if v := c.request.Header.Get("x-hawkflow-sdk-version"); v != Version {
+		t.Errorf("%v expected, got %v", Version, v)
+	}
+}
+
+func TestRemoteLimitsAreEnforced(t *testing.T) {
+	c := &ClientMock{returnStatusCode: 200, returnBody: `{"schema_version":1,"limits":{"process":{"max_length":10,"required":true}}}`}
+	hfc := New("api_key", OptionHTTPClient(c), OptionRemoteLimits(""))
+	err := hfc.Start("process_name_too_long", "", "")
+
+	expected := "Process parameter exceeded max length of 10 characters. Please see documentation at https://docs.hawkflow.ai/integration/index.html"
+	if err == nil || err.Error() != expected {
+		t.Errorf("%v expected, got %v", expected, err)
+	}
+	if n := DefaultLimits().Process.MaxLength; n != 250 {
+		t.Errorf("default limits must not change, got %v", n)
+	}
+}
+
+func TestRemoteLimitsWithSanitize(t *testing.T) {
+	// A max length of 0 means no limit.
+	c := &ClientMock{returnStatusCode: 200, returnBody: `{"schema_version":1,"limits":{"process":{"max_length":0}}}`}
+	hfc := New("api_key", OptionHTTPClient(c), OptionRemoteLimits(""), OptionSanitize(true))
+	if n := hfc.Limits().Process.MaxLength; n != 0 {
+		t.Fatalf("%v expected, got %v", 0, n)
+	}
+
+	c.returnStatusCode = 201
+	if err := hfc.Start("orders/eu.v2", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	r := &request{}
+	_ = json.NewDecoder(c.request.Body).Decode(r)
+	if r.Process != "orders_eu_v2" {
+		t.Errorf("%v expected, got %v", "orders_eu_v2", r.Process)
+	}
+}
+
+func TestRemoteLimitsDryRun(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "limits.json")
+	cache := `{"schema_version":1,"limits":{"process":{"max_length":20}}}`
+	if err := os.WriteFile(cacheFile, []byte(cache), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, testCase := range map[string]struct {
+		cacheFile string
+		expected  int
+	}{
+		"Cached limits":  {cacheFile: cacheFile, expected: 20},
+		"Default limits": {cacheFile: "", expected: defaultLimits.Process.MaxLength},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := &ClientMock{returnStatusCode: 200, returnBody: `{"schema_version":1,"limits":{"process":{"max_length":10}}}`}
+			hfc := New("api_key", OptionHTTPClient(c), OptionDryRun(io.Discard), OptionRemoteLimits(testCase.cacheFile))
+
+			if c.count != 0 {
+				t.Errorf("no requests expected, got %v", c.count)
+			}
+			if n := hfc.Limits().Process.MaxLength; n != testCase.expected {
+				t.Errorf("%v expected, got %v", testCase.expected, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+// Package hawkflowotel exports OpenTelemetry spans to HawkFlow, so code
+// already instrumented with OpenTelemetry does not need HawkFlow calls too.
+//
+// Every ended span becomes a HawkFlow run: the span name is the process, the
+// values of selected attributes form the meta and the span ID is the uid,
+// with the parent span ID as parent_uid. Exception events and error statuses
+// mark the run as failed with an Exception. Spans are batched by the
+// OpenTelemetry SDK and delivered through the Backfill method of a Client,
+// with its retries, rate limit and circuit breaker:
+//
+//	hf := hawkflow.New(apiKey, hawkflow.OptionSanitize(true))
+//	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(hawkflowotel.New(hf)))
+//
+// Span names often contain characters HawkFlow does not accept, such as "/"
+// in "GET /users/{id}", so use the client with OptionSanitize.
+package hawkflowotel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hawkflow/hawkflow-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Client is the part of the HawkFlow client used by the Exporter.
+type Client interface {
+	Backfill(run hawkflow.Run) error
+	Flush() error
+	Limits() hawkflow.LimitSet
+}
+
+// Exporter is an OpenTelemetry SpanExporter sending spans to HawkFlow.
+type Exporter struct {
+	client      Client
+	meta        []attribute.Key
+	processName func(sdktrace.ReadOnlySpan) string
+
+	mu      sync.Mutex
+	stopped bool
+}
+
+type Option func(*Exporter)
+
+// WithMetaAttributes sends the values of the given span attributes, in
+// order and separated by spaces, as meta.
+func WithMetaAttributes(keys ...attribute.Key) Option {
+	return func(e *Exporter) { e.meta = append(e.meta, keys...) }
+}
+
+// WithProcessName derives the process from a span instead of using its name.
+func WithProcessName(f func(sdktrace.ReadOnlySpan) string) Option {
+	return func(e *Exporter) { e.processName = f }
+}
+
+func New(client Client, options ...Option) *Exporter {
+	e := &Exporter{
+		client:      client,
+		processName: func(s sdktrace.ReadOnlySpan) string { return s.Name() },
+	}
+	for _, opt := range options {
+		opt(e)
+	}
+	return e
+}
+
+var _ sdktrace.SpanExporter = (*Exporter)(nil)
+
+// ErrShutdown is returned by ExportSpans after Shutdown.
+var ErrShutdown = errors.New("hawkflowotel: exporter is shut down")
+
+// ExportSpans sends every span as a run. Spans that fail are skipped; the
+// first error is returned along with how many spans failed. If ctx is done
+// first, ExportSpans returns its error while the remaining spans of the
+// current Backfill, which may be waiting for the rate limit or retrying,
+// finish in the background.
+func (e *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	stopped := e.stopped
+	e.mu.Unlock()
+	if stopped {
+		return ErrShutdown
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- e.export(ctx, spans) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("hawkflowotel: %d spans not exported: %w", len(spans), ctx.Err())
+	}
+}
+
+func (e *Exporter) export(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	var first error
+	failed := 0
+	for i, s := range spans {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("hawkflowotel: %d of %d spans not exported: %w", len(spans)-i+failed, len(spans), err)
+		}
+		if err := e.client.Backfill(e.run(s)); err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+
+	if first != nil {
+		return fmt.Errorf("hawkflowotel: %d of %d spans not exported: %w", failed, len(spans), first)
+	}
+	return nil
+}
+
+// Shutdown sends events still held back by the client's rate limit. Exporting
+// spans afterwards fails with ErrShutdown.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.stopped = true
+	e.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() { done <- e.client.Flush() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Exporter) run(s sdktrace.ReadOnlySpan) hawkflow.Run {
+	run := hawkflow.Run{
+		Process:   e.processName(s),
+		Meta:      e.metaOf(s),
+		UID:       s.SpanContext().SpanID().String(),
+		Start:     s.StartTime(),
+		End:       s.EndTime(),
+		Exception: e.exceptionOf(s),
+	}
+	if parent := s.Parent(); parent.IsValid() {
+		run.ParentUID = parent.SpanID().String()
+	}
+	return run
+}
+
+func (e *Exporter) metaOf(s sdktrace.ReadOnlySpan) string {
+	if len(e.meta) == 0 {
+		return ""
+	}
+
+	values := make([]string, 0, len(e.meta))
+	for _, key := range e.meta {
+		for _, kv := range s.Attributes() {
+			if kv.Key == key {
+				values = append(values, kv.Value.Emit())
+				break
+			}
+		}
+	}
+	return strings.Join(values, " ")
+}
+
+// exceptionOf combines the exception events of s into one message, falling
+// back to the description of an error status.
+func (e *Exporter) exceptionOf(s sdktrace.ReadOnlySpan) string {
+	var messages []string
+	for _, event := range s.Events() {
+		if event.Name != "exception" {
+			continue
+		}
+
+		var typ, message, stacktrace string
+		for _, kv := range event.Attributes {
+			switch kv.Key {
+			case "exception.type":
+				typ = kv.Value.Emit()
+			case "exception.message":
+				message = kv.Value.Emit()
+			case "exception.stacktrace":
+				stacktrace = kv.Value.Emit()
+			}
+		}
+
+		m := message
+		if typ != "" {
+			m = typ + ": " + message
+		}
+		if stacktrace != "" {
+			m += "\n" + stacktrace
+		}
+		messages = append(messages, m)
+	}
+
+	if len(messages) == 0 && s.Status().Code == codes.Error {
+		messages = append(messages, s.Status().Description)
+		if s.Status().Description == "" {
+			messages[0] = "Span ended with an error status."
+		}
+	}
+
+	exception := strings.Join(messages, "\n\n")
+	if max := e.client.Limits().Exception.MaxLength; max > 0 && len(exception) > max {
+		exception = exception[:max]
+	}
+	return exception
+}
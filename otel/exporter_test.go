@@ -0,0 +1,174 @@
+package hawkflowotel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hawkflow/hawkflow-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type clientMock struct {
+	runs    []hawkflow.Run
+	err     error
+	block   chan struct{}
+	flushed bool
+}
+
+func (c *clientMock) Backfill(run hawkflow.Run) error {
+	if c.block != nil {
+		<-c.block
+	}
+	if c.err != nil {
+		return c.err
+	}
+	c.runs = append(c.runs, run)
+	return nil
+}
+
+func (c *clientMock) Flush() error {
+	c.flushed = true
+	return nil
+}
+
+func (c *clientMock) Limits() hawkflow.LimitSet {
+	return hawkflow.DefaultLimits()
+}
+
+func newProvider(e *Exporter) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(e))
+}
+
+func TestExportSpans(t *testing.T) {
+	client := &clientMock{}
+	tp := newProvider(New(client, WithMetaAttributes("region", "tenant")))
+	tracer := tp.Tracer("test")
+
+	ctx, job := tracer.Start(context.Background(), "nightly_export",
+		trace.WithAttributes(attribute.String("tenant", "acme"), attribute.String("region", "eu")))
+	_, stage := tracer.Start(ctx, "load")
+	time.Sleep(time.Millisecond)
+	stage.RecordError(errors.New("bad row"))
+	stage.End()
+	job.End()
+
+	if len(client.runs) != 2 {
+		t.Fatalf("%v expected, got %v", 2, len(client.runs))
+	}
+	load, export := client.runs[0], client.runs[1]
+
+	if export.Process != "nightly_export" || export.Meta != "eu acme" || export.ParentUID != "" || export.Exception != "" {
+		t.Errorf("unexpected run %+v", export)
+	}
+	if export.UID != job.SpanContext().SpanID().String() {
+		t.Errorf("%v expected, got %v", job.SpanContext().SpanID(), export.UID)
+	}
+	if load.Process != "load" || load.ParentUID != export.UID || load.Exception != "*errors.errorString: bad row" {
+		t.Errorf("unexpected run %+v", load)
+	}
+	if !load.End.After(load.Start) {
+		t.Errorf("span times expected, got %v to %v", load.Start, load.End)
+	}
+}
+
+func TestExportSpansErrorStatus(t *testing.T) {
+	client := &clientMock{}
+	tracer := newProvider(New(client)).Tracer("test")
+
+	_, span := tracer.Start(context.Background(), "with_description")
+	span.SetStatus(codes.Error, "upstream unavailable")
+	span.End()
+	_, span = tracer.Start(context.Background(), "without_description")
+	span.SetStatus(codes.Error, "")
+	span.End()
+	_, span = tracer.Start(context.Background(), "long_exception")
+	span.AddEvent("exception", trace.WithAttributes(
+		attribute.String("exception.message", strings.Repeat("x", 20000)),
+	))
+	span.End()
+
+	expected := []string{"upstream unavailable", "Span ended with an error status.", strings.Repeat("x", hawkflow.DefaultLimits().Exception.MaxLength)}
+	for i, run := range client.runs {
+		if run.Exception != expected[i] {
+			t.Errorf("%.40q expected, got %.40q", expected[i], run.Exception)
+		}
+	}
+}
+
+func TestExportSpansFailure(t *testing.T) {
+	client := &clientMock{err: hawkflow.ErrCircuitOpen}
+	e := New(client)
+	tracer := newProvider(e).Tracer("test")
+
+	var spans []sdktrace.ReadOnlySpan
+	for i := 0; i < 3; i++ {
+		_, span := tracer.Start(context.Background(), "test_process")
+		span.End()
+		spans = append(spans, span.(sdktrace.ReadOnlySpan))
+	}
+
+	err := e.ExportSpans(context.Background(), spans)
+	if !errors.Is(err, hawkflow.ErrCircuitOpen) || !strings.Contains(err.Error(), "3 of 3 spans") {
+		t.Errorf("wrapped error expected, got %v", err)
+	}
+}
+
+func TestExportSpansContext(t *testing.T) {
+	client := &clientMock{block: make(chan struct{})}
+	defer close(client.block)
+	e := New(client)
+	_, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "test_process")
+	span.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := e.ExportSpans(ctx, []sdktrace.ReadOnlySpan{span.(sdktrace.ReadOnlySpan)})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("%v expected, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	client := &clientMock{}
+	e := New(client)
+	tp := newProvider(e)
+
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !client.flushed {
+		t.Errorf("flush expected")
+	}
+
+	_, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "test_process")
+	span.End()
+	if err := e.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{span.(sdktrace.ReadOnlySpan)}); !errors.Is(err, ErrShutdown) {
+		t.Errorf("%v expected, got %v", ErrShutdown, err)
+	}
+	if len(client.runs) != 0 {
+		t.Errorf("no runs expected, got %v", client.runs)
+	}
+}
+
+func TestWithProcessName(t *testing.T) {
+	client := &clientMock{}
+	tracer := newProvider(New(client, WithProcessName(func(s sdktrace.ReadOnlySpan) string {
+		return s.InstrumentationScope().Name + " " + s.Name()
+	}))).Tracer("billing")
+
+	_, span := tracer.Start(context.Background(), "charge")
+	span.End()
+
+	if client.runs[0].Process != "billing charge" {
+		t.Errorf("%v expected, got %v", "billing charge", client.runs[0].Process)
+	}
+}
+
+// The real client satisfies Client.
+var _ Client = hawkflow.New("api_key")
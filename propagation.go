@@ -0,0 +1,96 @@
+package hawkflow
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Headers carrying the active run between services.
+const (
+	_HEADER_RUN_PROCESS = "X-Hawkflow-Run-Process"
+	_HEADER_RUN_META    = "X-Hawkflow-Run-Meta"
+	_HEADER_RUN_UID     = "X-Hawkflow-Run-Uid"
+	_HEADER_RUN_PATH    = "X-Hawkflow-Run-Path"
+
+	// _MAX_PATH_DEPTH bounds the nesting accepted from incoming headers.
+	_MAX_PATH_DEPTH = 32
+)
+
+// InjectHeaders adds the run of the timer carried by ctx to the headers of
+// an outgoing request, so the receiving service can attach its events to it
+// with ExtractHeaders. It does nothing if ctx carries no timer.
+func InjectHeaders(ctx context.Context, h http.Header) {
+	if t := TimerFromContext(ctx); t != nil {
+		t.Inject(h)
+	}
+}
+
+// Inject adds the run of t to the headers of an outgoing request.
+func (t *Timer) Inject(h http.Header) {
+	h.Set(_HEADER_RUN_PROCESS, t.process)
+	h.Set(_HEADER_RUN_UID, t.uid)
+	if t.meta != "" {
+		h.Set(_HEADER_RUN_META, t.meta)
+	}
+	if t.path != t.process {
+		h.Set(_HEADER_RUN_PATH, t.path)
+	}
+	t.injectTraceparent(h)
+}
+
+// ExtractHeaders returns a copy of ctx carrying the run injected into the
+// headers of an incoming request, e.g. from an http.Handler:
+//
+//	ctx := hf.ExtractHeaders(r.Context(), r.Header)
+//	ctx, span, _ := hf.StartSpan(ctx, "resize_image", "")
+//
+// Spans started from it become children of the upstream timer, and
+// Exception and Metrics of the extracted timer are sent with its uid. The
+// extracted timer cannot be ended here. Without a valid run in the headers
+// ctx is returned unchanged, apart from the trace ID of OptionTraceparent.
+func (hfc *client) ExtractHeaders(ctx context.Context, h http.Header) context.Context {
+	if t := hfc.TimerFromHeaders(h); t != nil {
+		return ContextWithTimer(ctx, t)
+	}
+	return hfc.traceFromHeaders(ctx, h)
+}
+
+// TimerFromHeaders returns the run injected into the headers of an incoming
+// request, or nil if there is none or it is invalid.
+func (hfc *client) TimerFromHeaders(h http.Header) *Timer {
+	t := &Timer{
+		hfc:     hfc,
+		remote:  true,
+		process: h.Get(_HEADER_RUN_PROCESS),
+		meta:    h.Get(_HEADER_RUN_META),
+		uid:     h.Get(_HEADER_RUN_UID),
+		path:    h.Get(_HEADER_RUN_PATH),
+	}
+	if t.path == "" {
+		t.path = t.process
+	}
+	if hfc.traceparent {
+		t.trace, _ = parseTraceparent(h.Get(_HEADER_TRACEPARENT))
+	}
+
+	l := &hfc.limits
+	vs := l.appendProcess(nil, t.process)
+	vs = l.appendMeta(vs, t.meta)
+	vs = appendField(vs, "uid", t.uid, FieldLimit{MaxLength: l.UID.MaxLength, Required: true, Restricted: l.UID.Restricted}, "No UID set.", "UID parameter")
+	segments := strings.Split(t.path, "/")
+	if len(segments) > _MAX_PATH_DEPTH || segments[len(segments)-1] != t.process {
+		vs = append(vs, Violation{Field: "path", Message: "Invalid run path."})
+	}
+	for _, segment := range segments {
+		vs = appendField(vs, "path", segment, l.Process, "Empty run path segment.", "Path segment")
+	}
+
+	if len(vs) > 0 {
+		if hfc.debug {
+			hfc.logf("Ignored run headers: %s", newValidationError(vs, true))
+		}
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,105 @@
+package hawkflow
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHeaderPropagation(t *testing.T) {
+	upstream := New("api_key", OptionHTTPClient(&sampleRecorder{}))
+	ctx, job, _ := upstream.StartSpan(context.Background(), "nightly_export", "eu")
+	ctx, stage, _ := upstream.StartSpan(ctx, "load", "")
+
+	h := http.Header{}
+	InjectHeaders(ctx, h)
+
+	rec := &sampleRecorder{}
+	downstream := New("api_key", OptionHTTPClient(rec))
+	ctx = downstream.ExtractHeaders(context.Background(), h)
+
+	remote := TimerFromContext(ctx)
+	if remote == nil {
+		t.Fatal("timer expected, got nil")
+	}
+	if err := remote.Exception("resize failed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.End(); err == nil {
+		t.Errorf("error expected, got nil")
+	}
+
+	_, child, _ := downstream.StartSpan(ctx, "resize", "")
+	_ = child.End()
+
+	if len(rec.requests) != 3 {
+		t.Fatalf("%v expected, got %v", 3, len(rec.requests))
+	}
+	exception := rec.requests[0]
+	if exception.Process != "load" || exception.UID != stage.UID() || exception.ExceptionMessage != "resize failed" {
+		t.Errorf("exception for the upstream run expected, got %+v", exception)
+	}
+	for _, r := range rec.requests[1:] {
+		if r.Process != "resize" || r.ParentUID != stage.UID() || r.Path != "nightly_export/load/resize" {
+			t.Errorf("child of the upstream run expected, got %+v", r)
+		}
+	}
+
+	h = http.Header{}
+	job.Inject(h)
+	if got := downstream.TimerFromHeaders(h); got == nil || got.uid != job.UID() || got.meta != "eu" || got.path != "nightly_export" {
+		t.Errorf("top-level run expected, got %+v", got)
+	}
+}
+
+func TestTimerFromHeadersInvalid(t *testing.T) {
+	testCases := map[string]map[string]string{
+		"No headers":           {},
+		"Missing uid":          {_HEADER_RUN_PROCESS: "load"},
+		"Invalid process":      {_HEADER_RUN_PROCESS: "load ❌", _HEADER_RUN_UID: "uid"},
+		"Invalid uid":          {_HEADER_RUN_PROCESS: "load", _HEADER_RUN_UID: "<script>"},
+		"Path of another run":  {_HEADER_RUN_PROCESS: "load", _HEADER_RUN_UID: "uid", _HEADER_RUN_PATH: "job/parse"},
+		"Invalid path segment": {_HEADER_RUN_PROCESS: "load", _HEADER_RUN_UID: "uid", _HEADER_RUN_PATH: "job//load"},
+		"Path too deep":        {_HEADER_RUN_PROCESS: "load", _HEADER_RUN_UID: "uid", _HEADER_RUN_PATH: strings.Repeat("job/", 40) + "load"},
+	}
+
+	hfc := New("api_key")
+	for name, headers := range testCases {
+		t.Run(name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range headers {
+				h.Set(k, v)
+			}
+			if got := hfc.TimerFromHeaders(h); got != nil {
+				t.Errorf("nil expected, got %+v", got)
+			}
+			ctx := context.Background()
+			if hfc.ExtractHeaders(ctx, h) != ctx {
+				t.Errorf("unchanged context expected")
+			}
+		})
+	}
+}
+
+func TestContextWithTimerAcrossGoroutines(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec))
+	timer, _ := hfc.StartTimer("test_process", "", "test_uid")
+	ctx := ContextWithTimer(context.Background(), timer)
+
+	done := make(chan struct{})
+	go func(ctx context.Context) {
+		defer close(done)
+		_ = TimerFromContext(ctx).Metrics(map[string]float64{"rows": 1})
+	}(ctx)
+	<-done
+
+	if r := rec.requests[len(rec.requests)-1]; r.UID != "test_uid" || r.Items["rows"] != 1 {
+		t.Errorf("metrics for the timer's run expected, got %+v", r)
+	}
+	if TimerFromContext(context.Background()) != nil {
+		t.Errorf("nil expected for a context without timer")
+	}
+	InjectHeaders(context.Background(), http.Header{})
+}
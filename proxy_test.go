@@ -0,0 +1,87 @@
+package hawkflow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBypassProxy(t *testing.T) {
+	testCases := map[string]struct {
+		noProxy  string
+		url      string
+		expected bool
+	}{
+		"Empty list":            {"", "https://api.hawkflow.ai/v1/start", false},
+		"Wildcard":              {"*", "https://api.hawkflow.ai/v1/start", true},
+		"Exact host":            {"api.hawkflow.ai", "https://api.hawkflow.ai/v1/start", true},
+		"Domain suffix":         {"hawkflow.ai", "https://api.hawkflow.ai/v1/start", true},
+		"Leading dot":           {".hawkflow.ai", "https://api.hawkflow.ai/v1/start", true},
+		"Leading wildcard":      {"*.hawkflow.ai", "https://api.hawkflow.ai/v1/start", true},
+		"Partial label":         {"flow.ai", "https://api.hawkflow.ai/v1/start", false},
+		"Case insensitive":      {"API.HawkFlow.ai", "https://api.hawkflow.ai/v1/start", true},
+		"List with spaces":      {"localhost, internal.example", "https://api.internal.example/", true},
+		"Matching port":         {"hawkflow.ai:443", "https://api.hawkflow.ai/v1/start", true},
+		"Other port":            {"hawkflow.ai:8443", "https://api.hawkflow.ai/v1/start", false},
+		"IP":                    {"10.0.0.1", "http://10.0.0.1:8080/v1/start", true},
+		"CIDR":                  {"10.0.0.0/8", "http://10.1.2.3/v1/start", true},
+		"CIDR other network":    {"10.0.0.0/8", "http://192.168.1.1/v1/start", false},
+		"IPv6":                  {"::1", "http://[::1]:8080/v1/start", true},
+		"IP does not match DNS": {"10.0.0.1", "https://api.hawkflow.ai/v1/start", false},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			u, _ := url.Parse(testCase.url)
+			if got := bypassProxy(u, parseNoProxy(testCase.noProxy)); got != testCase.expected {
+				t.Errorf("%v expected, got %v", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestOptionProxy(t *testing.T) {
+	var proxied int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&proxied, 1)
+		if user, pass, _ := parseProxyAuthorization(r); user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer proxy.Close()
+
+	pu, _ := url.Parse(proxy.URL)
+	pu.User = url.UserPassword("user", "secret")
+
+	hfc := New("api_key", OptionEndpoint("http://api.hawkflow.invalid/v1/"), OptionProxy(pu.String(), ""), OptionMaxRetries(1))
+	if err := hfc.Start("test_process", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&proxied); n != 1 {
+		t.Errorf("%v expected, got %v", 1, n)
+	}
+
+	hfc = New("api_key", OptionEndpoint("http://api.hawkflow.invalid/v1/"), OptionProxy(pu.String(), "hawkflow.invalid"), OptionMaxRetries(1))
+	if err := hfc.Start("test_process", "", ""); err == nil {
+		t.Errorf("direct connection error expected, got nil")
+	}
+	if n := atomic.LoadInt32(&proxied); n != 1 {
+		t.Errorf("%v expected, got %v", 1, n)
+	}
+}
+
+func TestOptionProxyInvalidURL(t *testing.T) {
+	hfc := New("api_key", OptionProxy("://proxy", ""), OptionHTTPClient(&ClientMock{returnStatusCode: 201}))
+	if err := hfc.Start("test_process", "", ""); err == nil {
+		t.Errorf("error expected, got nil")
+	}
+}
+
+func parseProxyAuthorization(r *http.Request) (string, string, bool) {
+	req := &http.Request{Header: http.Header{"Authorization": r.Header["Proxy-Authorization"]}}
+	return req.BasicAuth()
+}
@@ -0,0 +1,348 @@
+package hawkflow
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// _MIN_BUCKET_SWEEP is the number of process buckets at which idle
+	// ones are first evicted.
+	_MIN_BUCKET_SWEEP = 64
+	// _MAX_PENDING_METRICS bounds the process and meta pairs with
+	// coalesced Metrics waiting to be sent.
+	_MAX_PENDING_METRICS = 1000
+)
+
+// ErrRateLimited is returned for events dropped by the client-side rate
+// limiter.
+var ErrRateLimited = createError("Rate limit exceeded, event dropped.")
+
+// RateLimitPolicy decides what happens to events beyond the rate limit.
+type RateLimitPolicy int
+
+const (
+	// RateLimitBlock waits until the event may be sent.
+	RateLimitBlock RateLimitPolicy = iota
+	// RateLimitDrop discards the event and returns ErrRateLimited.
+	RateLimitDrop
+	// RateLimitCoalesce merges excess Metrics calls for the same run, i.e.
+	// process, meta, uid, parent_uid and path, keeping the latest value of
+	// each item, and sends them with the next call for that run let through
+	// or on Flush. Other events are dropped.
+	RateLimitCoalesce
+)
+
+// OptionRateLimit limits the client to perSecond events with bursts of up to
+// burst events, handling excess events according to policy.
+func OptionRateLimit(perSecond float64, burst int, policy RateLimitPolicy) func(*client) {
+	return func(hfc *client) {
+		l := hfc.rateLimiter()
+		l.policy = policy
+		l.client = newBucket(perSecond, burst)
+	}
+}
+
+// OptionProcessRateLimit additionally limits each process name to perSecond
+// events with bursts of up to burst events. Excess events are handled by the
+// policy of OptionRateLimit, blocking by default.
+func OptionProcessRateLimit(perSecond float64, burst int) func(*client) {
+	return func(hfc *client) {
+		l := hfc.rateLimiter()
+		l.processRate = perSecond
+		l.processBurst = burst
+	}
+}
+
+func (hfc *client) rateLimiter() *limiter {
+	if hfc.limiter == nil {
+		hfc.limiter = &limiter{
+			processes: make(map[string]*bucket),
+			pending:   make(map[metricsKey]*request),
+			now:       time.Now,
+			sleep:     time.Sleep,
+		}
+	}
+	return hfc.limiter
+}
+
+// bucket is a token bucket. Tokens may go negative when blocking callers
+// reserve them ahead of time.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(perSecond float64, burst int) *bucket {
+	if perSecond <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucket{rate: perSecond, burst: float64(burst), tokens: float64(burst)}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+// idle reports whether the bucket has refilled completely by now, so it can
+// be replaced by a new one.
+func (b *bucket) idle(now time.Time) bool {
+	return now.Sub(b.last).Seconds()*b.rate >= b.burst-b.tokens
+}
+
+// wait returns how long until the bucket has a token again.
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+type coalesceKey struct {
+	process string
+	meta    string
+}
+
+// metricsKey identifies the run Metrics belong to, so Metrics of different
+// Timers of one process are never coalesced.
+type metricsKey struct {
+	process   string
+	meta      string
+	uid       string
+	parentUID string
+	path      string
+}
+
+func metricsKeyOf(r *request) metricsKey {
+	return metricsKey{r.Process, r.Meta, r.UID, r.ParentUID, r.Path}
+}
+
+type limiter struct {
+	policy       RateLimitPolicy
+	client       *bucket
+	processRate  float64
+	processBurst int
+
+	now   func() time.Time
+	sleep func(time.Duration)
+
+	mu        sync.Mutex
+	processes map[string]*bucket
+	sweepAt   int
+	swept     time.Time
+	pending   map[metricsKey]*request
+	delayed   uint64
+	dropped   uint64
+	coalesced uint64
+}
+
+// reserve takes a token from the client bucket and the bucket of process.
+// Without block it takes nothing and returns false if either is empty;
+// with block it always reserves and returns how long to wait.
+func (l *limiter) reserve(process string, block bool) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	buckets := make([]*bucket, 0, 2)
+	if l.client != nil {
+		buckets = append(buckets, l.client)
+	}
+	if l.processRate > 0 {
+		b, ok := l.processes[process]
+		if !ok {
+			l.sweep(now)
+			b = newBucket(l.processRate, l.processBurst)
+			l.processes[process] = b
+		}
+		buckets = append(buckets, b)
+	}
+
+	var wait time.Duration
+	for _, b := range buckets {
+		b.refill(now)
+		if w := b.wait(); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 && !block {
+		return 0, false
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	if wait > 0 {
+		l.delayed++
+	}
+	return wait, true
+}
+
+// sweep evicts idle process buckets once their number has doubled since the
+// last sweep or a bucket could have refilled since then, so many distinct
+// process names do not grow memory without bound.
+func (l *limiter) sweep(now time.Time) {
+	if len(l.processes) < _MIN_BUCKET_SWEEP {
+		return
+	}
+	burst := l.processBurst
+	if burst < 1 {
+		burst = 1
+	}
+	refill := time.Duration(float64(burst) / l.processRate * float64(time.Second))
+	if len(l.processes) < l.sweepAt && now.Sub(l.swept) < refill {
+		return
+	}
+
+	for process, b := range l.processes {
+		if b.idle(now) {
+			delete(l.processes, process)
+		}
+	}
+	l.sweepAt = 2 * len(l.processes)
+	l.swept = now
+}
+
+// limit applies the rate limit to r before it is sent. It returns false if
+// r must not be sent, with ErrRateLimited if it was dropped.
+func (hfc *client) limit(path string, r *request) (bool, error) {
+	l := hfc.limiter
+	if l == nil {
+		return true, nil
+	}
+
+	if l.policy == RateLimitBlock {
+		if wait, _ := l.reserve(r.Process, true); wait > 0 {
+			l.sleep(wait)
+		}
+		return true, nil
+	}
+
+	if _, ok := l.reserve(r.Process, false); ok {
+		if path == "metrics" && l.policy == RateLimitCoalesce {
+			hfc.takePending(r)
+		}
+		return true, nil
+	}
+
+	if path == "metrics" && l.policy == RateLimitCoalesce && hfc.coalesce(r) {
+		return false, nil
+	}
+
+	l.mu.Lock()
+	l.dropped++
+	l.mu.Unlock()
+	if hfc.debug {
+		hfc.logf("Rate limit exceeded, dropped %s: %s", path, r.Process)
+	}
+	return false, ErrRateLimited
+}
+
+// coalesce merges the items of r into the pending Metrics of the same run,
+// unless that would exceed the item limit, if there is one, or
+// _MAX_PENDING_METRICS. The pending Metrics keep the latest timestamp.
+func (hfc *client) coalesce(r *request) bool {
+	l := hfc.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := metricsKeyOf(r)
+	p, ok := l.pending[key]
+	if !ok && len(l.pending) >= _MAX_PENDING_METRICS {
+		return false
+	}
+	if ok {
+		added := 0
+		for k := range r.Items {
+			if _, ok := p.Items[k]; !ok {
+				added++
+			}
+		}
+		if max := hfc.limits.Items.MaxLength; max > 0 && len(p.Items)+added > max {
+			return false
+		}
+	}
+
+	if p == nil {
+		p = &request{
+			Process:   r.Process,
+			Meta:      r.Meta,
+			UID:       r.UID,
+			ParentUID: r.ParentUID,
+			Path:      r.Path,
+			Items:     make(map[string]float64, len(r.Items)),
+		}
+		l.pending[key] = p
+	}
+	for k, v := range r.Items {
+		p.Items[k] = v
+	}
+	if r.Timestamp != "" {
+		p.Timestamp = r.Timestamp
+	}
+	l.coalesced++
+	return true
+}
+
+// takePending adds pending coalesced items of the same run to r, which is
+// about to be sent. Items of r win over older pending values.
+func (hfc *client) takePending(r *request) {
+	l := hfc.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := metricsKeyOf(r)
+	p, ok := l.pending[key]
+	if !ok {
+		return
+	}
+	merged := make(map[string]float64, len(p.Items)+len(r.Items))
+	for k, v := range p.Items {
+		merged[k] = v
+	}
+	for k, v := range r.Items {
+		merged[k] = v
+	}
+	if max := hfc.limits.Items.MaxLength; max > 0 && len(merged) > max {
+		return
+	}
+
+	delete(l.pending, key)
+	r.Items = merged
+}
+
+// Flush sends Metrics coalesced by RateLimitCoalesce that are still pending,
+// waiting for the rate limit as needed. It returns the first error.
+func (hfc *client) Flush() error {
+	l := hfc.limiter
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	pending := l.pending
+	l.pending = make(map[metricsKey]*request)
+	l.mu.Unlock()
+
+	var first error
+	for _, r := range pending {
+		if wait, _ := l.reserve(r.Process, true); wait > 0 {
+			l.sleep(wait)
+		}
+		if err := hfc.sendWithRetry(r, "metrics", hfc.maxRetries); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,230 @@
+package hawkflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock drives a limiter without sleeping.
+func fakeClock(l *limiter) *time.Time {
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	l.sleep = func(d time.Duration) { now = now.Add(d) }
+	return &now
+}
+
+func TestRateLimitBlock(t *testing.T) {
+	mock := &ClientMock{returnStatusCode: 201}
+	hfc := New("api_key", OptionHTTPClient(mock), OptionRateLimit(10, 2, RateLimitBlock))
+	now := fakeClock(hfc.limiter)
+	started := *now
+
+	for i := 0; i < 5; i++ {
+		if err := hfc.Start("test_process", "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if mock.count != 5 {
+		t.Errorf("%v expected, got %v", 5, mock.count)
+	}
+	// Two events go out at once, the other three wait 100ms each.
+	if elapsed := now.Sub(started); elapsed != 300*time.Millisecond {
+		t.Errorf("%v expected, got %v", 300*time.Millisecond, elapsed)
+	}
+	if n := hfc.Stats().RateLimitDelayed; n != 3 {
+		t.Errorf("%v expected, got %v", 3, n)
+	}
+}
+
+func TestRateLimitDrop(t *testing.T) {
+	mock := &ClientMock{returnStatusCode: 201}
+	hfc := New("api_key", OptionHTTPClient(mock), OptionRateLimit(1, 1, RateLimitDrop))
+	now := fakeClock(hfc.limiter)
+
+	if err := hfc.Start("test_process", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := hfc.Start("test_process", "", ""); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("%v expected, got %v", ErrRateLimited, err)
+	}
+
+	*now = now.Add(time.Second)
+	if err := hfc.Start("test_process", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if mock.count != 2 {
+		t.Errorf("%v expected, got %v", 2, mock.count)
+	}
+	if n := hfc.Stats().RateLimitDropped; n != 1 {
+		t.Errorf("%v expected, got %v", 1, n)
+	}
+}
+
+func TestProcessRateLimit(t *testing.T) {
+	mock := &ClientMock{returnStatusCode: 201}
+	hfc := New("api_key", OptionHTTPClient(mock), OptionRateLimit(100, 100, RateLimitDrop), OptionProcessRateLimit(1, 1))
+	fakeClock(hfc.limiter)
+
+	if err := hfc.Start("runaway_process", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := hfc.Start("runaway_process", "", ""); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("%v expected, got %v", ErrRateLimited, err)
+	}
+	// Other processes are not affected.
+	if err := hfc.Start("other_process", "", ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimitCoalesce(t *testing.T) {
+	mock := &ClientMock{returnStatusCode: 201}
+	hfc := New("api_key", OptionHTTPClient(mock), OptionRateLimit(1, 1, RateLimitCoalesce))
+	now := fakeClock(hfc.limiter)
+
+	for i := 0; i < 5; i++ {
+		items := map[string]float64{"rows": float64(i)}
+		if i == 1 {
+			items["cols"] = 3
+		}
+		if err := hfc.Metrics("test_process", "", items); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if mock.count != 1 {
+		t.Errorf("%v expected, got %v", 1, mock.count)
+	}
+	// Other events cannot be coalesced.
+	if err := hfc.Start("test_process", "", ""); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("%v expected, got %v", ErrRateLimited, err)
+	}
+
+	*now = now.Add(time.Second)
+	if err := hfc.Metrics("test_process", "", map[string]float64{"size": 1}); err != nil {
+		t.Fatal(err)
+	}
+	r := &request{}
+	_ = json.NewDecoder(mock.request.Body).Decode(r)
+	expected := map[string]float64{"rows": 4, "cols": 3, "size": 1}
+	if len(r.Items) != len(expected) || r.Items["rows"] != 4 || r.Items["cols"] != 3 || r.Items["size"] != 1 {
+		t.Errorf("%v expected, got %v", expected, r.Items)
+	}
+
+	stats := hfc.Stats()
+	if stats.RateLimitCoalesced != 4 || stats.RateLimitDropped != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	mock := &ClientMock{returnStatusCode: 201}
+	hfc := New("api_key", OptionHTTPClient(mock), OptionRateLimit(1, 1, RateLimitCoalesce))
+	fakeClock(hfc.limiter)
+
+	_ = hfc.Metrics("test_process", "", map[string]float64{"rows": 1})
+	_ = hfc.Metrics("test_process", "", map[string]float64{"rows": 2})
+	if err := hfc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if mock.count != 2 {
+		t.Errorf("%v expected, got %v", 2, mock.count)
+	}
+	r := &request{}
+	_ = json.NewDecoder(mock.request.Body).Decode(r)
+	if r.Items["rows"] != 2 {
+		t.Errorf("%v expected, got %v", 2, r.Items["rows"])
+	}
+	if err := hfc.Flush(); err != nil || mock.count != 2 {
+		t.Errorf("nothing to flush expected, got %v after %v requests", err, mock.count)
+	}
+}
+
+func TestRateLimitCoalesceWithoutItemLimit(t *testing.T) {
+	mock := &ClientMock{returnStatusCode: 201}
+	hfc := New("api_key", OptionHTTPClient(mock), OptionRateLimit(1, 1, RateLimitCoalesce))
+	hfc.limits.Items.MaxLength = 0
+	fakeClock(hfc.limiter)
+
+	_ = hfc.Metrics("test_process", "", map[string]float64{"rows": 1})
+	if err := hfc.Metrics("test_process", "", map[string]float64{"rows": 2}); err != nil {
+		t.Errorf("coalesced metrics expected, got %v", err)
+	}
+	if n := hfc.Stats().RateLimitCoalesced; n != 1 {
+		t.Errorf("%v expected, got %v", 1, n)
+	}
+}
+
+func TestProcessRateLimitEvictsIdleBuckets(t *testing.T) {
+	hfc := New("api_key", OptionHTTPClient(&ClientMock{returnStatusCode: 201}), OptionProcessRateLimit(10, 2))
+	now := fakeClock(hfc.limiter)
+
+	for i := 0; i < 1000; i++ {
+		_ = hfc.Start(fmt.Sprintf("process_%d", i), "", "")
+	}
+	// Buckets refill after burst/rate, i.e. 200ms.
+	*now = now.Add(200 * time.Millisecond)
+	_ = hfc.Start("another_process", "", "")
+
+	if n := len(hfc.limiter.processes); n > _MIN_BUCKET_SWEEP {
+		t.Errorf("idle buckets evicted expected, got %v buckets", n)
+	}
+}
+
+func TestRateLimitCoalescePendingLimit(t *testing.T) {
+	hfc := New("api_key", OptionHTTPClient(&ClientMock{returnStatusCode: 201}), OptionRateLimit(1, 1, RateLimitCoalesce))
+	fakeClock(hfc.limiter)
+	_ = hfc.Metrics("test_process", "", map[string]float64{"rows": 1})
+
+	for i := 0; i < _MAX_PENDING_METRICS; i++ {
+		if err := hfc.Metrics("test_process", fmt.Sprintf("meta %d", i), map[string]float64{"rows": 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hfc.Metrics("test_process", "one too many", map[string]float64{"rows": 1}); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("%v expected, got %v", ErrRateLimited, err)
+	}
+	// Pending pairs can still be updated.
+	if err := hfc.Metrics("test_process", "meta 0", map[string]float64{"rows": 2}); err != nil {
+		t.Errorf("coalesced metrics expected, got %v", err)
+	}
+}
+
+func TestRateLimitCoalesceTimers(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec), OptionRateLimit(1, 4, RateLimitCoalesce), OptionTimestamps(true))
+	now := fakeClock(hfc.limiter)
+
+	runA, _ := hfc.StartTimer("test_process", "", "run_a")
+	runB, _ := hfc.StartTimer("test_process", "", "run_b")
+	stage, _ := runB.StartChild("load", "")
+	_ = runA.Metrics(map[string]float64{"rows_a": 1})
+	_ = runB.Metrics(map[string]float64{"rows_b": 1})
+	_ = stage.Metrics(map[string]float64{"rows_load": 1})
+
+	*now = now.Add(time.Second)
+	_ = runA.Metrics(map[string]float64{"rows_a": 2})
+	if err := hfc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := rec.requests[len(rec.requests)-3:]
+	if r := sent[0]; r.UID != "run_a" || len(r.Items) != 1 || r.Items["rows_a"] != 2 {
+		t.Errorf("only run_a items expected, got %+v", r)
+	}
+	flushed := map[string]request{}
+	for _, r := range sent[1:] {
+		flushed[r.UID] = r
+	}
+	if r := flushed["run_b"]; r.Items["rows_b"] != 1 || r.Timestamp == "" {
+		t.Errorf("run_b metrics expected, got %+v", r)
+	}
+	if r := flushed[stage.UID()]; r.ParentUID != "run_b" || r.Path != "test_process/load" || r.Items["rows_load"] != 1 {
+		t.Errorf("stage metrics expected, got %+v", r)
+	}
+}
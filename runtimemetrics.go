@@ -0,0 +1,244 @@
+package hawkflow
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// _RUNTIME_METRICS_INTERVAL is used by StartRuntimeMetrics for intervals
+// that are not positive.
+const _RUNTIME_METRICS_INTERVAL = time.Minute
+
+type runtimeMetricKind int
+
+const (
+	// runtimeGauge reports the current value.
+	runtimeGauge runtimeMetricKind = iota
+	// runtimeCounter reports the increase since the previous collection.
+	runtimeCounter
+	// runtimeHistogram reports the count, median, 99th percentile and
+	// maximum of the values observed since the previous collection.
+	runtimeHistogram
+)
+
+// runtimeMetrics are the runtime/metrics read by a RuntimeCollector and the
+// item keys they are reported as. Metrics the running Go version does not
+// support are skipped.
+var runtimeMetrics = []struct {
+	name string
+	key  string
+	kind runtimeMetricKind
+}{
+	{"/sched/goroutines:goroutines", "goroutines", runtimeGauge},
+	{"/memory/classes/total:bytes", "memory_total_bytes", runtimeGauge},
+	{"/memory/classes/heap/objects:bytes", "heap_objects_bytes", runtimeGauge},
+	{"/gc/heap/objects:objects", "heap_objects", runtimeGauge},
+	{"/gc/heap/goal:bytes", "heap_goal_bytes", runtimeGauge},
+	{"/gc/heap/allocs:bytes", "heap_allocs_bytes", runtimeCounter},
+	{"/gc/cycles/total:gc-cycles", "gc_cycles", runtimeCounter},
+	{"/gc/pauses:seconds", "gc_pause_seconds", runtimeHistogram},
+	{"/sched/latencies:seconds", "sched_latency_seconds", runtimeHistogram},
+}
+
+// RuntimeCollector periodically sends Go runtime health, such as heap size,
+// GC pauses, goroutines and scheduler latency, as Metrics.
+type RuntimeCollector struct {
+	hfc     *client
+	process string
+	meta    string
+
+	mu         sync.Mutex
+	samples    []metrics.Sample
+	keys       []string
+	kinds      []runtimeMetricKind
+	counters   []uint64
+	histograms [][]uint64
+	memStats   runtime.MemStats
+	mallocs    uint64
+	frees      uint64
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// StartRuntimeMetrics sends runtime metrics as Metrics of process and meta
+// every interval until Stop is called. Counters and histograms cover the time
+// since the previous send. Reading runtime.MemStats briefly stops the world,
+// so keep the interval in seconds or minutes. Send errors are reported
+// through the debug log.
+func (hfc *client) StartRuntimeMetrics(process, meta string, interval time.Duration) *RuntimeCollector {
+	if interval <= 0 {
+		interval = _RUNTIME_METRICS_INTERVAL
+	}
+
+	c := hfc.newRuntimeCollector(process, meta)
+	go c.run(interval)
+	return c
+}
+
+func (hfc *client) newRuntimeCollector(process, meta string) *RuntimeCollector {
+	c := &RuntimeCollector{
+		hfc:     hfc,
+		process: process,
+		meta:    meta,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	supported := make(map[string]bool)
+	for _, d := range metrics.All() {
+		supported[d.Name] = true
+	}
+	for _, m := range runtimeMetrics {
+		if !supported[m.name] {
+			continue
+		}
+		c.samples = append(c.samples, metrics.Sample{Name: m.name})
+		c.keys = append(c.keys, m.key)
+		c.kinds = append(c.kinds, m.kind)
+	}
+	c.counters = make([]uint64, len(c.samples))
+	c.histograms = make([][]uint64, len(c.samples))
+
+	// The first send covers the first interval rather than the whole life of
+	// the process.
+	c.read()
+	return c
+}
+
+func (c *RuntimeCollector) run(interval time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Collect(); err != nil {
+				c.hfc.logf("Runtime metrics not sent: %v", err)
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Stop ends the periodic sends and waits for a send in progress to finish.
+// It is safe to call more than once.
+func (c *RuntimeCollector) Stop() {
+	c.once.Do(func() { close(c.stop) })
+	<-c.done
+}
+
+// Collect sends the runtime metrics now.
+func (c *RuntimeCollector) Collect() error {
+	return c.hfc.Metrics(c.process, c.meta, c.read())
+}
+
+// read samples the runtime and returns the items to send.
+func (c *RuntimeCollector) read() map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	items := make(map[string]float64, 2*len(c.samples)+8)
+
+	metrics.Read(c.samples)
+	for i, s := range c.samples {
+		key := c.keys[i]
+		switch {
+		case c.kinds[i] == runtimeHistogram && s.Value.Kind() == metrics.KindFloat64Histogram:
+			h := s.Value.Float64Histogram()
+			counts := make([]uint64, len(h.Counts))
+			copy(counts, h.Counts)
+			addHistogram(items, key, h.Buckets, counts, c.histograms[i])
+			c.histograms[i] = counts
+		case s.Value.Kind() == metrics.KindUint64:
+			v := s.Value.Uint64()
+			if c.kinds[i] == runtimeCounter {
+				items[key] = float64(v - c.counters[i])
+				c.counters[i] = v
+			} else {
+				items[key] = float64(v)
+			}
+		case s.Value.Kind() == metrics.KindFloat64:
+			items[key] = s.Value.Float64()
+		}
+	}
+
+	m := &c.memStats
+	runtime.ReadMemStats(m)
+	items["memstats_heap_alloc_bytes"] = float64(m.HeapAlloc)
+	items["memstats_heap_sys_bytes"] = float64(m.HeapSys)
+	items["memstats_heap_idle_bytes"] = float64(m.HeapIdle)
+	items["memstats_heap_released_bytes"] = float64(m.HeapReleased)
+	items["memstats_stack_inuse_bytes"] = float64(m.StackInuse)
+	items["memstats_sys_bytes"] = float64(m.Sys)
+	items["memstats_mallocs"] = float64(m.Mallocs - c.mallocs)
+	items["memstats_frees"] = float64(m.Frees - c.frees)
+	c.mallocs, c.frees = m.Mallocs, m.Frees
+
+	return c.itemKeys(items)
+}
+
+// itemKeys sanitizes the keys of items to the client's item key limit.
+func (c *RuntimeCollector) itemKeys(items map[string]float64) map[string]float64 {
+	maxLength := c.hfc.limits.ItemKey.MaxLength
+	if maxLength <= 0 {
+		return items
+	}
+
+	sanitized := make(map[string]float64, len(items))
+	for k, v := range items {
+		sanitized[sanitize(k, maxLength)] = v
+	}
+	return sanitized
+}
+
+// addHistogram adds the count, median, 99th percentile and maximum of the
+// values counted since previous to items. The quantiles are reported as the
+// upper bound of their bucket and left out without new values.
+func addHistogram(items map[string]float64, key string, buckets []float64, counts, previous []uint64) {
+	delta := make([]uint64, len(counts))
+	var total uint64
+	for i, n := range counts {
+		if i < len(previous) {
+			n -= previous[i]
+		}
+		delta[i] = n
+		total += n
+	}
+
+	items[key+"_count"] = float64(total)
+	if total == 0 {
+		return
+	}
+	items[key+"_p50"] = histogramQuantile(buckets, delta, total, 0.5)
+	items[key+"_p99"] = histogramQuantile(buckets, delta, total, 0.99)
+	items[key+"_max"] = histogramQuantile(buckets, delta, total, 1)
+}
+
+func histogramQuantile(buckets []float64, counts []uint64, total uint64, q float64) float64 {
+	rank := uint64(math.Ceil(q * float64(total)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, n := range counts {
+		seen += n
+		if seen < rank {
+			continue
+		}
+		// Report the upper bound, or the lower bound of an unbounded bucket.
+		if upper := buckets[i+1]; !math.IsInf(upper, 1) {
+			return upper
+		}
+		return math.Max(buckets[i], 0)
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package hawkflow
+
+import (
+	"math"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRuntimeCollector(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec))
+	c := hfc.newRuntimeCollector("go_runtime", "api")
+
+	runtime.GC()
+	if err := c.Collect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.requests) != 1 {
+		t.Fatalf("%v expected, got %v", 1, len(rec.requests))
+	}
+	r := rec.requests[0]
+	if r.Process != "go_runtime" || r.Meta != "api" {
+		t.Errorf("unexpected request %+v", r)
+	}
+	for _, key := range []string{"goroutines", "memstats_heap_alloc_bytes", "memstats_sys_bytes"} {
+		if r.Items[key] <= 0 {
+			t.Errorf("%s expected, got %v", key, r.Items)
+		}
+	}
+	if r.Items["gc_cycles"] < 1 || r.Items["gc_pause_seconds_count"] < 1 {
+		t.Errorf("GC since the collector started expected, got %v", r.Items)
+	}
+	if len(r.Items) > defaultLimits.Items.MaxLength {
+		t.Errorf("at most %v items expected, got %v", defaultLimits.Items.MaxLength, len(r.Items))
+	}
+	for key := range r.Items {
+		if len(key) >= defaultLimits.ItemKey.MaxLength || !isAllowed(key) {
+			t.Errorf("invalid item key %q", key)
+		}
+	}
+}
+
+func TestRuntimeCollectorItemKeyLimit(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec))
+	hfc.limits.ItemKey.MaxLength = 20
+	c := hfc.newRuntimeCollector("go_runtime", "")
+
+	if err := c.Collect(); err != nil {
+		t.Fatal(err)
+	}
+	for key := range rec.requests[0].Items {
+		if len(key) > 20 {
+			t.Errorf("key of at most 20 characters expected, got %q", key)
+		}
+	}
+}
+
+// countingDoer counts requests from any goroutine.
+type countingDoer struct {
+	count int64
+}
+
+func (d *countingDoer) Do(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&d.count, 1)
+	_ = req.Body.Close()
+	return &http.Response{StatusCode: 201, Body: http.NoBody}, nil
+}
+
+func TestStartRuntimeMetrics(t *testing.T) {
+	doer := &countingDoer{}
+	hfc := New("api_key", OptionHTTPClient(doer))
+	c := hfc.StartRuntimeMetrics("go_runtime", "", time.Millisecond)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&doer.count) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("periodic sends expected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Stop()
+	c.Stop()
+	sent := atomic.LoadInt64(&doer.count)
+	time.Sleep(10 * time.Millisecond)
+	if n := atomic.LoadInt64(&doer.count); n != sent {
+		t.Errorf("no sends after Stop expected, got %v", n-sent)
+	}
+}
+
+func TestAddHistogram(t *testing.T) {
+	buckets := []float64{math.Inf(-1), 1, 2, 3, math.Inf(1)}
+
+	items := map[string]float64{}
+	addHistogram(items, "latency", buckets, []uint64{1, 5, 4, 0}, []uint64{1, 0, 0, 0})
+	expected := map[string]float64{"latency_count": 9, "latency_p50": 2, "latency_p99": 3, "latency_max": 3}
+	for k, v := range expected {
+		if items[k] != v {
+			t.Errorf("%s: %v expected, got %v", k, v, items[k])
+		}
+	}
+
+	// Values in the unbounded bucket are reported as its lower bound.
+	items = map[string]float64{}
+	addHistogram(items, "latency", buckets, []uint64{0, 0, 0, 2}, nil)
+	if items["latency_max"] != 3 {
+		t.Errorf("%v expected, got %v", 3, items["latency_max"])
+	}
+
+	// Without new values only the count is reported.
+	items = map[string]float64{}
+	addHistogram(items, "latency", buckets, []uint64{1, 5, 4, 0}, []uint64{1, 5, 4, 0})
+	if len(items) != 1 || items["latency_count"] != 0 {
+		t.Errorf("zero count expected, got %v", items)
+	}
+}
@@ -0,0 +1,200 @@
+package hawkflow
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// _MAX_UNPAIRED_STARTS bounds the sampling decisions remembered for
+	// Starts without a uid that have not been ended yet, per process and
+	// meta.
+	_MAX_UNPAIRED_STARTS = 1000
+	// _MAX_UNPAIRED_KEYS bounds the process and meta pairs with remembered
+	// decisions. Ends of pairs beyond it are sampled independently.
+	_MAX_UNPAIRED_KEYS = 10000
+	// _MIN_UNPAIRED_SWEEP is the number of pairs at which decisions not
+	// used for _UNPAIRED_TTL are first evicted.
+	_MIN_UNPAIRED_SWEEP = 64
+	_UNPAIRED_TTL       = time.Hour
+)
+
+// OptionSampleRate sends only a fraction rate, between 0 and 1, of the
+// Start/End pairs. Pairs with a uid are kept or dropped together by hashing
+// the process and uid; without a uid the decision made at Start is applied to
+// the next End of the same process and meta. Exceptions, Metrics and Ends
+// marked as failed are always sent. Sent events carry sample_rate so counts
+// can be scaled.
+//
+// A Start that was dropped cannot be sent later, so a failed run started
+// with Start shows up as an End without its Start. A Timer keeps its dropped
+// Start and sends it, with the original timestamp, when an Exception is sent
+// through the Timer or one of its children.
+func OptionSampleRate(rate float64) func(*client) {
+	return func(hfc *client) { hfc.sampler().rate = clampRate(rate) }
+}
+
+// OptionProcessSampleRate overrides the sample rate of OptionSampleRate for
+// process.
+func OptionProcessSampleRate(process string, rate float64) func(*client) {
+	return func(hfc *client) { hfc.sampler().processes[process] = clampRate(rate) }
+}
+
+func clampRate(rate float64) float64 {
+	if rate < 0 || math.IsNaN(rate) {
+		return 0
+	}
+	if rate > 1 {
+		return 1
+	}
+	return rate
+}
+
+func (hfc *client) sampler() *sampler {
+	if hfc.sampling == nil {
+		hfc.sampling = &sampler{
+			rate:      1,
+			processes: make(map[string]float64),
+			random:    rand.Float64,
+			now:       time.Now,
+			unpaired:  make(map[coalesceKey]*decisions),
+		}
+	}
+	return hfc.sampling
+}
+
+type sampler struct {
+	rate      float64
+	processes map[string]float64
+	random    func() float64
+	now       func() time.Time
+
+	mu       sync.Mutex
+	unpaired map[coalesceKey]*decisions
+	sweepAt  int
+	swept    time.Time
+	dropped  uint64
+}
+
+// decisions are the sampling decisions of Starts waiting for their End.
+type decisions struct {
+	keep    []bool
+	touched time.Time
+}
+
+func (s *sampler) rateOf(process string) float64 {
+	if rate, ok := s.processes[process]; ok {
+		return rate
+	}
+	return s.rate
+}
+
+// keep decides whether a Start or End is sent.
+func (s *sampler) keep(path string, r *request, rate float64) bool {
+	if r.UID != "" {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(r.Process))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(r.UID))
+		return float64(mix64(h.Sum64())>>11)/(1<<53) < rate
+	}
+
+	key := coalesceKey{r.Process, r.Meta}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if path == "end" {
+		if d, ok := s.unpaired[key]; ok {
+			keep := d.keep[0]
+			if len(d.keep) == 1 {
+				delete(s.unpaired, key)
+			} else {
+				d.keep = d.keep[1:]
+				d.touched = now
+			}
+			return keep
+		}
+		return s.random() < rate
+	}
+
+	keep := s.random() < rate
+	d, ok := s.unpaired[key]
+	if !ok {
+		s.sweep(now)
+		if len(s.unpaired) >= _MAX_UNPAIRED_KEYS {
+			return keep
+		}
+		d = &decisions{}
+		s.unpaired[key] = d
+	}
+	if len(d.keep) < _MAX_UNPAIRED_STARTS {
+		d.keep = append(d.keep, keep)
+	}
+	d.touched = now
+	return keep
+}
+
+// sweep evicts decisions not used for _UNPAIRED_TTL once the number of pairs
+// has doubled or _UNPAIRED_TTL has passed since the last sweep, so Starts
+// that are never ended do not grow memory without bound.
+func (s *sampler) sweep(now time.Time) {
+	if len(s.unpaired) < _MIN_UNPAIRED_SWEEP {
+		return
+	}
+	if len(s.unpaired) < s.sweepAt && now.Sub(s.swept) < _UNPAIRED_TTL {
+		return
+	}
+
+	for key, d := range s.unpaired {
+		if now.Sub(d.touched) >= _UNPAIRED_TTL {
+			delete(s.unpaired, key)
+		}
+	}
+	s.sweepAt = 2 * len(s.unpaired)
+	s.swept = now
+}
+
+// mix64 is the splitmix64 finalizer. FNV alone leaves the high bits nearly
+// unchanged for uids that differ only in their last characters.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// sampledOut reports whether the Start r, which has a uid, will be dropped.
+func (hfc *client) sampledOut(r *request) bool {
+	s := hfc.sampling
+	if s == nil {
+		return false
+	}
+	rate := s.rateOf(r.Process)
+	return rate < 1 && !s.keep("start", r, rate)
+}
+
+// sample reports whether r is sent, setting its sample rate if so.
+func (hfc *client) sample(path string, r *request) bool {
+	s := hfc.sampling
+	if s == nil || (path != "start" && path != "end") || (path == "end" && r.Failed) {
+		return true
+	}
+
+	rate := s.rateOf(r.Process)
+	if rate >= 1 {
+		return true
+	}
+	if !s.keep(path, r, rate) {
+		s.mu.Lock()
+		s.dropped++
+		s.mu.Unlock()
+		return false
+	}
+	r.SampleRate = rate
+	return true
+}
@@ -0,0 +1,141 @@
+package hawkflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// sampleRecorder decodes every request sent through it.
+type sampleRecorder struct {
+	requests []request
+}
+
+func (s *sampleRecorder) Do(req *http.Request) (*http.Response, error) {
+	r := request{}
+	_ = json.NewDecoder(req.Body).Decode(&r)
+	_ = req.Body.Close()
+	s.requests = append(s.requests, r)
+	return &http.Response{StatusCode: 201, Body: http.NoBody}, nil
+}
+
+func TestSampleRateKeepsPairsByUID(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec), OptionSampleRate(0.3))
+
+	for i := 0; i < 1000; i++ {
+		uid := fmt.Sprintf("uid_%d", i)
+		_ = hfc.Start("test_process", "", uid)
+		_ = hfc.End("test_process", "", uid)
+	}
+
+	if n := len(rec.requests); n%2 != 0 || n < 500 || n > 700 {
+		t.Errorf("about 600 events expected, got %v", n)
+	}
+	for i := 0; i < len(rec.requests); i += 2 {
+		start, end := rec.requests[i], rec.requests[i+1]
+		if start.UID != end.UID {
+			t.Fatalf("pair expected, got %v and %v", start.UID, end.UID)
+		}
+		if start.SampleRate != 0.3 {
+			t.Errorf("%v expected, got %v", 0.3, start.SampleRate)
+		}
+	}
+	if s := hfc.Stats(); int(s.SampledOut)+len(rec.requests) != 2000 {
+		t.Errorf("%v expected, got %v", 2000-len(rec.requests), s.SampledOut)
+	}
+}
+
+func TestSampleRateWithoutUID(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec), OptionSampleRate(0.5))
+	decisions := []float64{0.1, 0.9, 0.2}
+	hfc.sampling.random = func() float64 {
+		d := decisions[0]
+		decisions = decisions[1:]
+		return d
+	}
+
+	// Nested Starts of the same process are ended in order.
+	_ = hfc.Start("test_process", "", "")
+	_ = hfc.Start("test_process", "", "")
+	_ = hfc.End("test_process", "", "")
+	_ = hfc.End("test_process", "", "")
+	_ = hfc.Start("test_process", "", "")
+	_ = hfc.End("test_process", "", "")
+
+	if n := len(rec.requests); n != 4 {
+		t.Errorf("%v expected, got %v", 4, n)
+	}
+}
+
+func TestProcessSampleRate(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec), OptionSampleRate(0), OptionProcessSampleRate("important_process", 1))
+
+	_ = hfc.Start("hot_process", "", "uid")
+	_ = hfc.Start("important_process", "", "uid")
+	_ = hfc.Exception("hot_process", "", "exception message")
+	_ = hfc.Metrics("hot_process", "", map[string]float64{"rows": 1})
+
+	if n := len(rec.requests); n != 3 {
+		t.Fatalf("%v expected, got %v", 3, n)
+	}
+	if rec.requests[0].Process != "important_process" || rec.requests[0].SampleRate != 0 {
+		t.Errorf("unsampled important_process expected, got %+v", rec.requests[0])
+	}
+	if rec.requests[1].ExceptionMessage == "" || rec.requests[1].SampleRate != 0 {
+		t.Errorf("unsampled exception expected, got %+v", rec.requests[1])
+	}
+}
+
+func TestSampleRateKeepsFailedTimers(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec), OptionSampleRate(0))
+
+	job, _ := hfc.StartTimer("nightly_export", "", "run_1")
+	stage, _ := job.StartChild("load", "")
+	ok, _ := hfc.StartTimer("nightly_export", "", "run_2")
+	if len(rec.requests) != 0 {
+		t.Fatalf("sampled out Starts expected, got %+v", rec.requests)
+	}
+
+	_ = stage.Exception("disk full")
+	_ = stage.End()
+	_ = job.End()
+	_ = ok.End()
+
+	expected := []struct {
+		process string
+		failed  bool
+	}{{"nightly_export", false}, {"load", false}, {"load", false}, {"load", true}, {"nightly_export", true}}
+	if len(rec.requests) != len(expected) {
+		t.Fatalf("%v events expected, got %+v", len(expected), rec.requests)
+	}
+	for i, r := range rec.requests {
+		if r.Process != expected[i].process || r.Failed != expected[i].failed || r.SampleRate != 0 {
+			t.Errorf("%+v expected, got %+v", expected[i], r)
+		}
+	}
+	if rec.requests[0].Timestamp == "" || rec.requests[1].ParentUID != "run_1" {
+		t.Errorf("original Starts expected, got %+v", rec.requests[:2])
+	}
+}
+
+func TestSampleRateEvictsUnpairedStarts(t *testing.T) {
+	hfc := New("api_key", OptionHTTPClient(&sampleRecorder{}), OptionSampleRate(0.5))
+	now := time.Unix(1700000000, 0)
+	hfc.sampling.now = func() time.Time { return now }
+
+	for i := 0; i < 1000; i++ {
+		_ = hfc.Start("test_process", fmt.Sprintf("meta %d", i), "")
+	}
+	now = now.Add(_UNPAIRED_TTL)
+	_ = hfc.Start("test_process", "another meta", "")
+
+	if n := len(hfc.sampling.unpaired); n != 1 {
+		t.Errorf("%v expected, got %v", 1, n)
+	}
+}
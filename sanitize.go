@@ -0,0 +1,117 @@
+package hawkflow
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// transliterations maps common accented Latin letters to their ASCII base.
+var transliterations = map[rune]string{
+	'ß': "ss", 'Æ': "AE", 'æ': "ae", 'Œ': "OE", 'œ': "oe",
+	'Þ': "Th", 'þ': "th", 'Ð': "D", 'ð': "d", 'Ĳ': "IJ", 'ĳ': "ij",
+	'–': "-", '—': "-", '‐': "-", '‑': "-",
+}
+
+func init() {
+	groups := map[string]string{
+		"a": "àáâãäåāăą", "A": "ÀÁÂÃÄÅĀĂĄ",
+		"c": "çćĉċč", "C": "ÇĆĈĊČ",
+		"d": "ďđ", "D": "ĎĐ",
+		"e": "èéêëēĕėęě", "E": "ÈÉÊËĒĔĖĘĚ",
+		"g": "ĝğġģ", "G": "ĜĞĠĢ",
+		"h": "ĥħ", "H": "ĤĦ",
+		"i": "ìíîïĩīĭįı", "I": "ÌÍÎÏĨĪĬĮİ",
+		"j": "ĵ", "J": "Ĵ",
+		"k": "ķ", "K": "Ķ",
+		"l": "ĺļľŀł", "L": "ĹĻĽĿŁ",
+		"n": "ñńņň", "N": "ÑŃŅŇ",
+		"o": "òóôõöøōŏő", "O": "ÒÓÔÕÖØŌŎŐ",
+		"r": "ŕŗř", "R": "ŔŖŘ",
+		"s": "śŝşšș", "S": "ŚŜŞŠȘ",
+		"t": "ţťŧț", "T": "ŢŤŦȚ",
+		"u": "ùúûüũūŭůűų", "U": "ÙÚÛÜŨŪŬŮŰŲ",
+		"w": "ŵ", "W": "Ŵ",
+		"y": "ýÿŷ", "Y": "ÝŸŶ",
+		"z": "źżž", "Z": "ŹŻŽ",
+	}
+	for ascii, letters := range groups {
+		for _, r := range letters {
+			transliterations[r] = ascii
+		}
+	}
+}
+
+// OptionSanitize repairs process, meta and uid values that would fail
+// validation instead of rejecting the event. Changes are reported through the
+// debug log.
+func OptionSanitize(b bool) func(*client) {
+	return func(hfc *client) { hfc.sanitize = b }
+}
+
+func (hfc *client) sanitizeRequest(r *request) {
+	hfc.sanitizeField("process", &r.Process, hfc.limits.Process.MaxLength)
+	hfc.sanitizeField("meta", &r.Meta, hfc.limits.Meta.MaxLength)
+	hfc.sanitizeField("uid", &r.UID, hfc.limits.UID.MaxLength)
+}
+
+func (hfc *client) sanitizeField(name string, value *string, maxLength int) {
+	s := sanitize(*value, maxLength)
+	if s != *value {
+		hfc.logf("Sanitized %s %q to %q", name, *value, s)
+		*value = s
+	}
+}
+
+// sanitize transliterates common Unicode letters to ASCII, maps . / and : to
+// underscores, drops any other unsupported character and collapses
+// whitespace. Values longer than maxLength are truncated and suffixed with a
+// hash of the original so that distinct values stay distinct, unless
+// maxLength leaves no room for the hash. A maxLength of 0 means no limit.
+func sanitize(s string, maxLength int) string {
+	if isAllowed(s) && (maxLength <= 0 || len(s) <= maxLength) {
+		return s
+	}
+
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			space = b.Len() > 0
+			continue
+		}
+
+		var repl string
+		switch {
+		case r == '.' || r == '/' || r == ':':
+			repl = "_"
+		case r < unicode.MaxASCII && isAllowedByte(byte(r)):
+			repl = string(r)
+		default:
+			repl = transliterations[r]
+		}
+		if repl == "" {
+			continue
+		}
+
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteString(repl)
+	}
+
+	out := b.String()
+	if maxLength > 0 && len(out) > maxLength {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(s))
+		suffix := fmt.Sprintf("_%08x", h.Sum32())
+		if maxLength <= len(suffix) {
+			return out[:maxLength]
+		}
+		out = out[:maxLength-len(suffix)] + suffix
+	}
+
+	return out
+}
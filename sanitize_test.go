@@ -0,0 +1,119 @@
+package hawkflow
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	long := strings.Repeat("a", 60)
+
+	testCases := map[string]struct {
+		value     string
+		maxLength int
+		expected  string
+	}{
+		"Valid value is unchanged": {
+			value:     "test_process - 1",
+			maxLength: 250,
+			expected:  "test_process - 1",
+		},
+		"Separators are mapped to underscores": {
+			value:     "orders/eu.v2:daily",
+			maxLength: 250,
+			expected:  "orders_eu_v2_daily",
+		},
+		"Unicode is transliterated": {
+			value:     "Überprüfung café straße Łódź",
+			maxLength: 250,
+			expected:  "Uberprufung cafe strasse Lodz",
+		},
+		"Unsupported characters are dropped": {
+			value:     "invalid process ❌",
+			maxLength: 250,
+			expected:  "invalid process",
+		},
+		"Whitespace is collapsed": {
+			value:     "  orders \t\n eu/v2  ",
+			maxLength: 250,
+			expected:  "orders eu_v2",
+		},
+		"Long value is truncated with hash": {
+			value:     long + ".",
+			maxLength: 50,
+			expected:  strings.Repeat("a", 41) + "_399d962d",
+		},
+		"Short limit truncates without hash": {
+			value:     "orders/eu.v2",
+			maxLength: 5,
+			expected:  "order",
+		},
+		"No limit": {
+			value:     long + ".",
+			maxLength: 0,
+			expected:  long + "_",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s := sanitize(testCase.value, testCase.maxLength)
+
+			if s != testCase.expected {
+				t.Errorf("%v expected, got %v", testCase.expected, s)
+			}
+			if testCase.maxLength > 0 && len(s) > testCase.maxLength {
+				t.Errorf("at most %v characters expected, got %v", testCase.maxLength, len(s))
+			}
+			if err := validateProcess(s); s != "" && err != nil {
+				t.Errorf("sanitized value failed validation: %v", err)
+			}
+		})
+	}
+}
+
+func TestSanitizeTruncationAvoidsCollisions(t *testing.T) {
+	prefix := strings.Repeat("b", 60)
+	a := sanitize(prefix+"_one", 50)
+	b := sanitize(prefix+"_two", 50)
+
+	if a == b {
+		t.Errorf("distinct values expected, got %v for both", a)
+	}
+	if sanitize(prefix+"_one", 50) != a {
+		t.Errorf("truncation should be stable")
+	}
+}
+
+func TestOptionSanitize(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := &ClientMock{returnStatusCode: 201}
+	hfc := New("api_key", OptionHTTPClient(c), OptionSanitize(true), OptionDebug(true), OptionLogger(log.New(buf, "", 0)))
+	err := hfc.Start("orders/eu.v2", "région: nord", "run.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqBody, _ := io.ReadAll(c.request.Body)
+	expected := `{"process":"orders_eu_v2","meta":"region_ nord","uid":"run_1"}`
+	if strings.TrimSpace(string(reqBody)) != expected {
+		t.Errorf("%v expected, got %v", expected, strings.TrimSpace(string(reqBody)))
+	}
+	if !strings.Contains(buf.String(), `Sanitized process "orders/eu.v2" to "orders_eu_v2"`) {
+		t.Errorf("sanitization was not logged: %v", buf.String())
+	}
+}
+
+func TestOptionSanitizeDisabled(t *testing.T) {
+	c := &ClientMock{returnStatusCode: 201}
+	hfc := New("api_key", OptionHTTPClient(c))
+	err := hfc.Start("orders/eu.v2", "", "")
+
+	expected := "Process parameter contains unsupported characters. Please see documentation at https://docs.hawkflow.ai/integration/index.html"
+	if err == nil || err.Error() != expected {
+		t.Errorf("%v expected, got %v", expected, err)
+	}
+}
@@ -0,0 +1,40 @@
+package hawkflow
+
+import "context"
+
+type timerKey struct{}
+
+// ContextWithTimer returns a copy of ctx carrying t, e.g. to hand the active
+// run to goroutines working on it.
+func ContextWithTimer(ctx context.Context, t *Timer) context.Context {
+	return context.WithValue(ctx, timerKey{}, t)
+}
+
+// TimerFromContext returns the timer carried by ctx, or nil.
+func TimerFromContext(ctx context.Context) *Timer {
+	t, _ := ctx.Value(timerKey{}).(*Timer)
+	return t
+}
+
+// StartSpan starts a timer nested in the timer carried by ctx, or a new
+// top-level timer if there is none, using the incoming trace ID as its uid
+// with OptionTraceparent, and returns a context carrying the new
+// timer for the stages below it:
+//
+//	ctx, job, _ := hf.StartSpan(ctx, "nightly_export", "")
+//	defer job.End()
+//	ctx, stage, _ := hf.StartSpan(ctx, "load", "")
+//	defer stage.End()
+func (hfc *client) StartSpan(ctx context.Context, process, meta string) (context.Context, *Timer, error) {
+	parent := TimerFromContext(ctx)
+	uid, trace := "", ""
+	if parent == nil {
+		if trace = traceFromContext(ctx); trace != "" {
+			uid = sanitize(trace, hfc.limits.UID.MaxLength)
+		}
+	}
+
+	t, err := hfc.startTimer(parent, process, meta, uid)
+	t.trace = trace
+	return ContextWithTimer(ctx, t), t, err
+}
@@ -0,0 +1,72 @@
+package hawkflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStartSpan(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec))
+
+	ctx, job, err := hfc.StartSpan(context.Background(), "nightly_export", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stageCtx, stage, _ := hfc.StartSpan(ctx, "load", "")
+	_, step, _ := hfc.StartSpan(stageCtx, "parse", "")
+	_, sibling, _ := hfc.StartSpan(ctx, "report", "")
+
+	_ = step.Exception("bad row")
+	_ = step.End()
+	_ = stage.End()
+	_ = sibling.End()
+	_ = job.End()
+
+	byProcess := make(map[string][]request)
+	for _, r := range rec.requests {
+		byProcess[r.Process] = append(byProcess[r.Process], r)
+	}
+
+	testCases := map[string]struct {
+		parentUID string
+		path      string
+		failed    bool
+	}{
+		"nightly_export": {"", "", true},
+		"load":           {job.UID(), "nightly_export/load", true},
+		"parse":          {stage.UID(), "nightly_export/load/parse", true},
+		"report":         {job.UID(), "nightly_export/report", false},
+	}
+	for process, expected := range testCases {
+		events := byProcess[process]
+		if len(events) == 0 {
+			t.Fatalf("events for %s expected, got none", process)
+		}
+		for _, r := range events {
+			if r.UID == "" || r.ParentUID != expected.parentUID || r.Path != expected.path {
+				t.Errorf("%s: %+v expected, got %+v", process, expected, r)
+			}
+		}
+		end := events[len(events)-1]
+		if end.Failed != expected.failed {
+			t.Errorf("%s: failed %v expected, got %v", process, expected.failed, end.Failed)
+		}
+	}
+	if n := len(byProcess["parse"]); n != 3 {
+		t.Errorf("%v expected, got %v", 3, n)
+	}
+}
+
+func TestStartChildSanitizedPath(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec), OptionSanitize(true))
+
+	job, _ := hfc.StartTimer("jobs/nightly", "", "")
+	stage, _ := job.StartChild("load.csv", "")
+	_ = stage.End()
+
+	if r := rec.requests[len(rec.requests)-1]; r.Path != "jobs_nightly/load_csv" {
+		t.Errorf("%v expected, got %v", "jobs_nightly/load_csv", r.Path)
+	}
+}
@@ -0,0 +1,49 @@
+package hawkflow
+
+// Stats is a snapshot of the client's internal counters.
+type Stats struct {
+	// Circuit is the circuit breaker state, CircuitClosed without
+	// OptionCircuitBreaker.
+	Circuit CircuitState
+	// ConsecutiveFailures counts failed requests since the last success.
+	ConsecutiveFailures int
+	// CircuitOpened counts how often the circuit breaker opened.
+	CircuitOpened uint64
+	// CircuitRejected counts requests failed with ErrCircuitOpen.
+	CircuitRejected uint64
+
+	// RateLimitDelayed counts events held back by RateLimitBlock.
+	RateLimitDelayed uint64
+	// RateLimitDropped counts events discarded by the rate limit.
+	RateLimitDropped uint64
+	// RateLimitCoalesced counts Metrics calls merged by RateLimitCoalesce.
+	RateLimitCoalesced uint64
+
+	// SampledOut counts Start and End events not sent due to sampling.
+	SampledOut uint64
+}
+
+func (hfc *client) Stats() Stats {
+	var s Stats
+	if b := hfc.breaker; b != nil {
+		b.mu.Lock()
+		s.Circuit = b.state
+		s.ConsecutiveFailures = b.failures
+		s.CircuitOpened = b.opens
+		s.CircuitRejected = b.rejected
+		b.mu.Unlock()
+	}
+	if l := hfc.limiter; l != nil {
+		l.mu.Lock()
+		s.RateLimitDelayed = l.delayed
+		s.RateLimitDropped = l.dropped
+		s.RateLimitCoalesced = l.coalesced
+		l.mu.Unlock()
+	}
+	if sp := hfc.sampling; sp != nil {
+		sp.mu.Lock()
+		s.SampledOut = sp.dropped
+		sp.mu.Unlock()
+	}
+	return s
+}
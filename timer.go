@@ -0,0 +1,192 @@
+package hawkflow
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Timer is a started process returned by StartTimer. Its End reports the
+// duration measured on the client's monotonic clock, which is unaffected by
+// network latency, retries and wall clock changes.
+type Timer struct {
+	hfc     *client
+	parent  *Timer
+	process string
+	meta    string
+	uid     string
+	path    string
+	started time.Time
+	ended   uint32
+	failed  uint32
+	// remote timers were started by another service and extracted from
+	// request headers.
+	remote bool
+	// trace is the incoming W3C trace ID a top-level timer was started from.
+	trace string
+	// heldStart is the Start dropped by sampling, sent if the run fails.
+	heldStart *request
+	held      uint32
+}
+
+// StartTimer sends a Start like Start and returns a Timer to end it with. An
+// empty uid is replaced by a random one, so the Start and End always pair up
+// and child timers can refer to it. The Timer is returned even if sending
+// the Start failed, so the End can still be reported.
+func (hfc *client) StartTimer(process, meta, uid string) (*Timer, error) {
+	return hfc.startTimer(nil, process, meta, uid)
+}
+
+// StartChild starts a timer for a stage of t. The child's Start and End carry
+// the uid of t as parent_uid and the process names from the outermost timer
+// down, joined by "/", as path.
+func (t *Timer) StartChild(process, meta string) (*Timer, error) {
+	return t.hfc.startTimer(t, process, meta, "")
+}
+
+func (hfc *client) startTimer(parent *Timer, process, meta, uid string) (*Timer, error) {
+	if uid == "" {
+		uid = newIdempotencyKey()
+	}
+	t := &Timer{hfc: hfc, parent: parent, started: hfc.clock.Now()}
+
+	r := &request{Process: process, Meta: meta, UID: uid}
+	if hfc.timestamps {
+		r.Timestamp = formatTimestamp(t.started)
+	}
+	if hfc.sanitize {
+		hfc.sanitizeRequest(r)
+	}
+	t.process, t.meta, t.uid = r.Process, r.Meta, r.UID
+	t.path = t.process
+	if parent != nil {
+		t.path = parent.path + "/" + t.process
+	}
+
+	t.span(r)
+	held := hfc.sampledOut(r)
+	if err := hfc.event("start", r); err != nil || !held {
+		return t, err
+	}
+	if r.Timestamp == "" {
+		r.Timestamp = formatTimestamp(t.started)
+	}
+	t.heldStart = r
+	t.held = 1
+	return t, nil
+}
+
+// sendHeldStarts sends the Starts of t and its parents that were dropped by
+// sampling, outermost first, so a failed run is recorded in full.
+func (t *Timer) sendHeldStarts() error {
+	var timers []*Timer
+	for p := t; p != nil; p = p.parent {
+		if atomic.CompareAndSwapUint32(&p.held, 1, 0) {
+			timers = append(timers, p)
+		}
+	}
+
+	for i := len(timers) - 1; i >= 0; i-- {
+		r := timers[i].heldStart
+		timers[i].heldStart = nil
+		if err := t.hfc.deliverSampled(r, "start"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// span adds the nesting of t to r.
+func (t *Timer) span(r *request) {
+	if t.parent == nil {
+		return
+	}
+	r.ParentUID = t.parent.uid
+	r.Path = t.path
+}
+
+// End sends the End of the timer with its duration, marked as failed if an
+// Exception was sent through it or one of its children. Only the first call
+// sends an event.
+func (t *Timer) End() error {
+	if t.remote {
+		return createError("Timer was started by another service and cannot be ended here.")
+	}
+	if !atomic.CompareAndSwapUint32(&t.ended, 0, 1) {
+		return createError("Timer already ended.")
+	}
+
+	now := t.hfc.clock.Now()
+	r := &request{
+		Process:    t.process,
+		Meta:       t.meta,
+		UID:        t.uid,
+		DurationMs: durationMs(now.Sub(t.started)),
+		Failed:     t.Failed(),
+	}
+	if t.hfc.timestamps {
+		r.Timestamp = formatTimestamp(now)
+	}
+	t.span(r)
+	return t.hfc.event("end", r)
+}
+
+// Exception sends an Exception for the timer's process, meta and uid and
+// marks the timer and all its parents as failed. Starts of these timers that
+// were dropped by sampling are sent first.
+func (t *Timer) Exception(message string) error {
+	for p := t; p != nil; p = p.parent {
+		atomic.StoreUint32(&p.failed, 1)
+	}
+	if err := t.sendHeldStarts(); err != nil {
+		return err
+	}
+
+	r := &request{
+		Process:          t.process,
+		Meta:             t.meta,
+		UID:              t.uid,
+		ExceptionMessage: message,
+	}
+	t.span(r)
+	return t.hfc.event("exception", r)
+}
+
+// Metrics sends Metrics for the timer's process, meta and uid.
+func (t *Timer) Metrics(items map[string]float64) error {
+	r := &request{
+		Process: t.process,
+		Meta:    t.meta,
+		UID:     t.uid,
+		Items:   t.hfc.repairItems(items),
+	}
+	t.span(r)
+	return t.hfc.event("metrics", r)
+}
+
+// Failed reports whether an Exception was sent through t or a child of t.
+func (t *Timer) Failed() bool {
+	return atomic.LoadUint32(&t.failed) == 1
+}
+
+// UID returns the uid the timer's events are sent with.
+func (t *Timer) UID() string {
+	return t.uid
+}
+
+// Elapsed returns the time since the timer was started, 0 for a timer
+// started by another service.
+func (t *Timer) Elapsed() time.Duration {
+	if t.remote {
+		return 0
+	}
+	return t.hfc.clock.Now().Sub(t.started)
+}
+
+// durationMs converts d to milliseconds. A zero duration, which would be
+// left out of the payload, is reported as the smallest positive value.
+func durationMs(d time.Duration) float64 {
+	if d <= 0 {
+		return 0.001
+	}
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,127 @@
+package hawkflow
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestOptionTimestamps(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.FixedZone("CET", 3600))}
+
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec), OptionClock(clock), OptionTimestamps(true))
+	_ = hfc.Start("test_process", "", "")
+	_ = hfc.Metrics("test_process", "", map[string]float64{"rows": 1})
+
+	hfc = New("api_key", OptionHTTPClient(rec), OptionClock(clock))
+	_ = hfc.Start("test_process", "", "")
+
+	expected := []string{"2024-01-02T02:04:05.006Z", "2024-01-02T02:04:05.006Z", ""}
+	for i, r := range rec.requests {
+		if r.Timestamp != expected[i] {
+			t.Errorf("%v expected, got %v", expected[i], r.Timestamp)
+		}
+	}
+}
+
+func TestTimer(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec), OptionClock(clock), OptionTimestamps(true))
+
+	timer, err := hfc.StartTimer("test_process", "test_meta", "test_uid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(1500 * time.Millisecond)
+	if err := timer.Exception("exception message"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := timer.Elapsed(); elapsed != 1500*time.Millisecond {
+		t.Errorf("%v expected, got %v", 1500*time.Millisecond, elapsed)
+	}
+	if err := timer.End(); err != nil {
+		t.Fatal(err)
+	}
+	if err := timer.End(); err == nil {
+		t.Errorf("error expected, got nil")
+	}
+
+	if len(rec.requests) != 3 {
+		t.Fatalf("%v expected, got %v", 3, len(rec.requests))
+	}
+	start, exception, end := rec.requests[0], rec.requests[1], rec.requests[2]
+	if start.Timestamp != "2024-01-02T03:04:05Z" || start.DurationMs != 0 {
+		t.Errorf("unexpected start %+v", start)
+	}
+	if exception.UID != "test_uid" || exception.ExceptionMessage != "exception message" {
+		t.Errorf("unexpected exception %+v", exception)
+	}
+	if end.Timestamp != "2024-01-02T03:04:06.5Z" || end.DurationMs != 1500 || end.UID != "test_uid" || end.Meta != "test_meta" {
+		t.Errorf("unexpected end %+v", end)
+	}
+}
+
+func TestTimerValidatesUID(t *testing.T) {
+	hfc := New("api_key", OptionHTTPClient(&sampleRecorder{}))
+	timer, _ := hfc.StartTimer("test_process", "", "test_uid")
+	timer.uid = "invalid uid ❌"
+
+	if err := timer.Metrics(map[string]float64{"rows": 1}); err == nil {
+		t.Errorf("error expected, got nil")
+	}
+}
+
+func TestReplayTimestamps(t *testing.T) {
+	log := `{"time":"2024-01-02T03:04:05.5+01:00","seq":1,"event":"start","body":{"process":"test_process"}}
+{"time":"2024-01-02T03:04:06+01:00","seq":2,"event":"end","body":{"process":"test_process","timestamp":"2024-01-02T02:04:05.9Z"}}`
+
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec))
+	if _, err := hfc.Replay(strings.NewReader(log), ReplayTimestamps()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.requests) != 2 {
+		t.Fatalf("%v expected, got %v", 2, len(rec.requests))
+	}
+	if ts := rec.requests[0].Timestamp; ts != "2024-01-02T02:04:05.5Z" {
+		t.Errorf("%v expected, got %v", "2024-01-02T02:04:05.5Z", ts)
+	}
+	if ts := rec.requests[1].Timestamp; ts != "2024-01-02T02:04:05.9Z" {
+		t.Errorf("%v expected, got %v", "2024-01-02T02:04:05.9Z", ts)
+	}
+}
+
+func TestOptionClockDrivesCircuitBreaker(t *testing.T) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	hfc := New("api_key", OptionHTTPClient(&ClientMock{returnStatusCode: 503}), OptionMaxRetries(1),
+		OptionCircuitBreaker(1, time.Minute), OptionClock(clock))
+
+	_ = hfc.Start("test_process", "", "")
+	clock.Advance(time.Minute)
+	_ = hfc.Start("test_process", "", "")
+
+	if s := hfc.Stats(); s.CircuitRejected != 0 || s.CircuitOpened != 2 {
+		t.Errorf("probe after cooldown expected, got %+v", s)
+	}
+}
@@ -0,0 +1,120 @@
+package hawkflow
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// OptionRootCAs trusts the certificates in the given PEM files in addition to
+// the system roots, e.g. for a TLS intercepting proxy with a private CA.
+func OptionRootCAs(pemFiles ...string) func(*client) {
+	return func(hfc *client) { hfc.transport.rootCAs = append(hfc.transport.rootCAs, pemFiles...) }
+}
+
+// OptionClientCertificate presents the PEM encoded certificate and key for
+// mutual TLS. Both files are reloaded when they change, so rotated
+// certificates are picked up without restarting.
+func OptionClientCertificate(certFile, keyFile string) func(*client) {
+	return func(hfc *client) { hfc.transport.cert = &certReloader{certFile: certFile, keyFile: keyFile} }
+}
+
+// OptionMinTLSVersion sets the minimum TLS version, e.g. tls.VersionTLS13.
+// It defaults to TLS 1.2.
+func OptionMinTLSVersion(version uint16) func(*client) {
+	return func(hfc *client) {
+		if version < tls.VersionTLS10 || version > tls.VersionTLS13 {
+			hfc.transport.err = createError(fmt.Sprintf("Unsupported TLS version %#x.", version))
+			return
+		}
+		hfc.transport.minTLSVersion = version
+	}
+}
+
+func (t *transportConfig) tlsConfig(base *tls.Config) (*tls.Config, error) {
+	c := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		c = base.Clone()
+	}
+	if t.minTLSVersion != 0 {
+		c.MinVersion = t.minTLSVersion
+	}
+
+	if len(t.rootCAs) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, file := range t.rootCAs {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				return nil, createError(fmt.Sprintf("Reading root CAs failed: %s.", err))
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, createError(fmt.Sprintf("No certificates found in %s.", file))
+			}
+		}
+		c.RootCAs = pool
+	}
+
+	if t.cert != nil {
+		if _, err := t.cert.load(); err != nil {
+			return nil, createError(fmt.Sprintf("Loading client certificate failed: %s.", err))
+		}
+		c.GetClientCertificate = t.cert.getClientCertificate
+	}
+
+	return c, nil
+}
+
+// certReloader loads a certificate and key pair again whenever either file's
+// modification time changes. A failed reload keeps the previous pair.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func (cr *certReloader) load() (*tls.Certificate, error) {
+	certInfo, err := os.Stat(cr.certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo, err := os.Stat(cr.keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.cert != nil && certInfo.ModTime().Equal(cr.certMod) && keyInfo.ModTime().Equal(cr.keyMod) {
+		return cr.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cr.cert, cr.certMod, cr.keyMod = &cert, certInfo.ModTime(), keyInfo.ModTime()
+	return cr.cert, nil
+}
+
+func (cr *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, err := cr.load()
+	if err != nil {
+		cr.mu.Lock()
+		defer cr.mu.Unlock()
+		if cr.cert != nil {
+			return cr.cert, nil
+		}
+		return nil, err
+	}
+	return cert, nil
+}
@@ -0,0 +1,192 @@
+package hawkflow
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM encoded certificate and key for commonName.
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte, mod time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newMTLSServer answers 201 to clients with a certificate issued by ca and
+// records their common names.
+func newMTLSServer(t *testing.T, ca *testCA) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var names []string
+
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		names = append(names, r.TLS.PeerCertificates[0].Subject.CommonName)
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	certPEM, keyPEM := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	s.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	s.StartTLS()
+
+	return s, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), names...)
+	}
+}
+
+func TestOptionClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	s, names := newMTLSServer(t, ca)
+	defer s.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+
+	mod := time.Now().Add(-time.Minute)
+	writeFile(t, caFile, ca.pem, mod)
+	certPEM, keyPEM := ca.issue(t, "client_1", x509.ExtKeyUsageClientAuth)
+	writeFile(t, certFile, certPEM, mod)
+	writeFile(t, keyFile, keyPEM, mod)
+
+	hfc := New("api_key",
+		OptionEndpoint(s.URL+"/v1/"),
+		OptionRootCAs(caFile),
+		OptionClientCertificate(certFile, keyFile),
+		OptionMinTLSVersion(tls.VersionTLS13),
+		OptionMaxRetries(1),
+	)
+	if err := hfc.Start("test_process", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate the certificate; new connections present the new one.
+	certPEM, keyPEM = ca.issue(t, "client_2", x509.ExtKeyUsageClientAuth)
+	writeFile(t, certFile, certPEM, mod.Add(time.Second))
+	writeFile(t, keyFile, keyPEM, mod.Add(time.Second))
+	hfc.httpClient.(*http.Client).Transport.(*http.Transport).CloseIdleConnections()
+
+	if err := hfc.End("test_process", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	got := names()
+	if len(got) != 2 || got[0] != "client_1" || got[1] != "client_2" {
+		t.Errorf("%v expected, got %v", []string{"client_1", "client_2"}, got)
+	}
+}
+
+func TestOptionRootCAsRequired(t *testing.T) {
+	ca := newTestCA(t)
+	s, _ := newMTLSServer(t, ca)
+	defer s.Close()
+
+	// The server certificate is not trusted without the private CA.
+	hfc := New("api_key", OptionEndpoint(s.URL+"/v1/"), OptionMaxRetries(1))
+	if err := hfc.Start("test_process", "", ""); err == nil {
+		t.Errorf("error expected, got nil")
+	}
+}
+
+func TestTransportOptionErrors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "not.pem")
+	writeFile(t, notPEM, []byte("not a certificate"), time.Now())
+
+	testCases := map[string][]option{
+		"Missing root CA file":     {OptionRootCAs(filepath.Join(dir, "missing.pem"))},
+		"Root CA file without PEM": {OptionRootCAs(notPEM)},
+		"Missing client cert":      {OptionClientCertificate(filepath.Join(dir, "missing.pem"), notPEM)},
+		"Unsupported TLS version":  {OptionMinTLSVersion(0x0200)},
+		"Custom HTTP client":       {OptionHTTPClient(&ClientMock{returnStatusCode: 201}), OptionMinTLSVersion(tls.VersionTLS12)},
+	}
+
+	for name, options := range testCases {
+		t.Run(name, func(t *testing.T) {
+			hfc := New("api_key", options...)
+			if err := hfc.Start("test_process", "", ""); err == nil {
+				t.Errorf("error expected, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package hawkflow
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const _HEADER_TRACEPARENT = "Traceparent"
+
+type traceKey struct{}
+
+// OptionTraceparent lines HawkFlow runs up with W3C trace context.
+// ExtractHeaders then accepts an incoming traceparent header when there is no
+// HawkFlow run in the headers, and the top-level timer started from that
+// context uses the trace ID as its uid. Timer.Inject and InjectHeaders also
+// set traceparent, unless the headers already carry one.
+func OptionTraceparent(b bool) func(*client) {
+	return func(hfc *client) { hfc.traceparent = b }
+}
+
+// UIDFromTraceparent returns the trace ID of a W3C traceparent header value,
+// e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sanitized
+// for use as a uid.
+func UIDFromTraceparent(traceparent string) (string, bool) {
+	traceID, ok := parseTraceparent(traceparent)
+	if !ok {
+		return "", false
+	}
+	return sanitize(traceID, defaultLimits.UID.MaxLength), true
+}
+
+// parseTraceparent returns the trace ID of a valid traceparent.
+func parseTraceparent(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 {
+		return "", false
+	}
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", false
+	}
+	if len(traceID) != 32 || !isLowerHex(traceID) || strings.Trim(traceID, "0") == "" {
+		return "", false
+	}
+	if len(parentID) != 16 || !isLowerHex(parentID) || strings.Trim(parentID, "0") == "" {
+		return "", false
+	}
+	if len(flags) != 2 || !isLowerHex(flags) {
+		return "", false
+	}
+	return traceID, true
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// traceFromHeaders returns ctx carrying the trace ID of an incoming
+// traceparent header.
+func (hfc *client) traceFromHeaders(ctx context.Context, h http.Header) context.Context {
+	if !hfc.traceparent {
+		return ctx
+	}
+	traceID, ok := parseTraceparent(h.Get(_HEADER_TRACEPARENT))
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, traceKey{}, traceID)
+}
+
+func traceFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceKey{}).(string)
+	return traceID
+}
+
+// traceID returns the trace ID of t: the incoming one it was started from, or
+// one derived from the uid of its top-level timer.
+func (t *Timer) traceID() string {
+	root := t
+	for root.parent != nil {
+		root = root.parent
+	}
+	if root.trace != "" {
+		return root.trace
+	}
+	if len(root.uid) == 32 && isLowerHex(root.uid) {
+		return root.uid
+	}
+	sum := sha256.Sum256([]byte(root.uid))
+	return hex.EncodeToString(sum[:16])
+}
+
+// injectTraceparent sets traceparent with the trace ID of t and a span ID
+// derived from its uid.
+func (t *Timer) injectTraceparent(h http.Header) {
+	if !t.hfc.traceparent || h.Get(_HEADER_TRACEPARENT) != "" {
+		return
+	}
+	sum := sha256.Sum256([]byte(t.uid))
+	h.Set(_HEADER_TRACEPARENT, "00-"+t.traceID()+"-"+hex.EncodeToString(sum[:8])+"-01")
+}
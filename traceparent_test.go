@@ -0,0 +1,111 @@
+package hawkflow
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestUIDFromTraceparent(t *testing.T) {
+	testCases := map[string]struct {
+		traceparent string
+		uid         string
+		ok          bool
+	}{
+		"Valid":              {testTraceparent, "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		"Unsampled":          {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		"Future version":     {"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		"Empty":              {"", "", false},
+		"Invalid version":    {"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "", false},
+		"Extra fields in 00": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "", false},
+		"Uppercase":          {"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "", false},
+		"Zero trace ID":      {"00-00000000000000000000000000000000-00f067aa0ba902b7-01", "", false},
+		"Zero parent ID":     {"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", "", false},
+		"Short trace ID":     {"00-4bf92f3577b34da6-00f067aa0ba902b7-01", "", false},
+		"Not hex":            {"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", "", false},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uid, ok := UIDFromTraceparent(testCase.traceparent)
+			if uid != testCase.uid || ok != testCase.ok {
+				t.Errorf("%v %v expected, got %v %v", testCase.uid, testCase.ok, uid, ok)
+			}
+		})
+	}
+}
+
+func TestOptionTraceparent(t *testing.T) {
+	rec := &sampleRecorder{}
+	hfc := New("api_key", OptionHTTPClient(rec), OptionTraceparent(true))
+
+	in := http.Header{}
+	in.Set("traceparent", testTraceparent)
+	ctx := hfc.ExtractHeaders(context.Background(), in)
+	ctx, job, _ := hfc.StartSpan(ctx, "nightly_export", "")
+	ctx, stage, _ := hfc.StartSpan(ctx, "load", "")
+
+	if job.UID() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("%v expected, got %v", "4bf92f3577b34da6a3ce929d0e0e4736", job.UID())
+	}
+	if stage.UID() == job.UID() || rec.requests[1].ParentUID != job.UID() {
+		t.Errorf("child with its own uid expected, got %+v", rec.requests[1])
+	}
+
+	out := http.Header{}
+	InjectHeaders(ctx, out)
+	tp := out.Get("traceparent")
+	if !strings.HasPrefix(tp, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || !strings.HasSuffix(tp, "-01") {
+		t.Errorf("traceparent of the incoming trace expected, got %v", tp)
+	}
+	if _, ok := parseTraceparent(tp); !ok {
+		t.Errorf("valid traceparent expected, got %v", tp)
+	}
+
+	// A traceparent set by a tracer is left alone.
+	out = http.Header{}
+	out.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	stage.Inject(out)
+	if tp := out.Get("traceparent"); tp != "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01" {
+		t.Errorf("existing traceparent expected, got %v", tp)
+	}
+}
+
+func TestOptionTraceparentDisabled(t *testing.T) {
+	hfc := New("api_key", OptionHTTPClient(&sampleRecorder{}))
+
+	in := http.Header{}
+	in.Set("traceparent", testTraceparent)
+	ctx, job, _ := hfc.StartSpan(hfc.ExtractHeaders(context.Background(), in), "nightly_export", "")
+	if job.UID() == "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("random uid expected, got %v", job.UID())
+	}
+
+	out := http.Header{}
+	InjectHeaders(ctx, out)
+	if tp := out.Get("traceparent"); tp != "" {
+		t.Errorf("no traceparent expected, got %v", tp)
+	}
+}
+
+func TestTraceparentWithoutIncomingTrace(t *testing.T) {
+	hfc := New("api_key", OptionHTTPClient(&sampleRecorder{}), OptionTraceparent(true))
+	job, _ := hfc.StartTimer("nightly_export", "", "job 42")
+	stage, _ := job.StartChild("load", "")
+
+	h1, h2 := http.Header{}, http.Header{}
+	job.Inject(h1)
+	stage.Inject(h2)
+
+	trace1, ok1 := parseTraceparent(h1.Get("traceparent"))
+	trace2, ok2 := parseTraceparent(h2.Get("traceparent"))
+	if !ok1 || !ok2 || trace1 != trace2 {
+		t.Errorf("one trace for the run expected, got %v and %v", h1.Get("traceparent"), h2.Get("traceparent"))
+	}
+	if h1.Get("traceparent") == h2.Get("traceparent") {
+		t.Errorf("distinct span IDs expected, got %v", h1.Get("traceparent"))
+	}
+}
@@ -0,0 +1,165 @@
+package hawkflow
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const _REDACTED = "REDACTED"
+
+// TrafficRecord is a single request attempt as written by OptionTrafficLog.
+type TrafficRecord struct {
+	Time      time.Time         `json:"time"`
+	Seq       uint64            `json:"seq"`
+	Event     string            `json:"event"`
+	Endpoint  string            `json:"endpoint"`
+	Headers   map[string]string `json:"headers"`
+	Body      json.RawMessage   `json:"body"`
+	Status    int               `json:"status,omitempty"`
+	LatencyMs float64           `json:"latency_ms"`
+	Response  string            `json:"response,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+type trafficLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// OptionTrafficLog writes every request attempt to w as JSON Lines, with the
+// API key redacted. The file can be fed back through Replay.
+func OptionTrafficLog(w io.Writer) func(*client) {
+	return func(hfc *client) { hfc.traffic = &trafficLog{enc: json.NewEncoder(w)} }
+}
+
+func (hfc *client) recordTraffic(rec *TrafficRecord, req *http.Request) {
+	if hfc.traffic == nil {
+		return
+	}
+
+	rec.Headers = redactHeaders(req.Header)
+	rec.Response = hfc.redact(rec.Response)
+	rec.Error = hfc.redact(rec.Error)
+
+	hfc.traffic.mu.Lock()
+	defer hfc.traffic.mu.Unlock()
+	if err := hfc.traffic.enc.Encode(rec); err != nil {
+		hfc.logf("Traffic log write failed: %s", err)
+	}
+}
+
+func (hfc *client) redact(s string) string {
+	if hfc.apiKey == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, hfc.apiKey, _REDACTED)
+}
+
+func redactHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+	for k := range h {
+		headers[strings.ToLower(k)] = h.Get(k)
+	}
+	if _, ok := headers["x-hawkflow-api-key"]; ok {
+		headers["x-hawkflow-api-key"] = _REDACTED
+	}
+	return headers
+}
+
+type replayer struct {
+	interval   time.Duration
+	timestamps bool
+}
+
+type replayOption func(*replayer)
+
+// ReplayRate limits Replay to perSecond events per second.
+func ReplayRate(perSecond float64) func(*replayer) {
+	return func(rp *replayer) {
+		if perSecond > 0 {
+			rp.interval = time.Duration(float64(time.Second) / perSecond)
+		}
+	}
+}
+
+// ReplayTimestamps stamps events logged without a timestamp with the time
+// they were originally sent, so they are not recorded at the replay time.
+func ReplayTimestamps() func(*replayer) {
+	return func(rp *replayer) { rp.timestamps = true }
+}
+
+// Replay re-sends the events of a traffic log written by OptionTrafficLog.
+// Retried attempts of the same event are sent only once. Replay stops at the
+// first malformed line or failed event and returns how many events were sent.
+func (hfc *client) Replay(r io.Reader, options ...replayOption) (int, error) {
+	rp := &replayer{}
+	for _, opt := range options {
+		opt(rp)
+	}
+
+	seen := make(map[string]bool)
+	sent := 0
+	var next time.Time
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var rec TrafficRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return sent, fmt.Errorf("traffic log line %d: %w", line, err)
+		}
+		// Retries share the idempotency key. The seq only identifies
+		// attempts of one client run, so it is used for logs written before
+		// idempotency keys only.
+		id := rec.Headers["idempotency-key"]
+		if id == "" && rec.Seq != 0 {
+			id = "seq:" + strconv.FormatUint(rec.Seq, 10)
+		}
+		if id != "" {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+		}
+
+		req := &request{}
+		if err := json.Unmarshal(rec.Body, req); err != nil {
+			return sent, fmt.Errorf("traffic log line %d: %w", line, err)
+		}
+		// Reuse the original key so events the API already received are
+		// not duplicated.
+		req.key = rec.Headers["idempotency-key"]
+		if rp.timestamps && req.Timestamp == "" && !rec.Time.IsZero() {
+			req.Timestamp = formatTimestamp(rec.Time)
+		}
+		if err := hfc.validate(rec.Event, req); err != nil {
+			return sent, fmt.Errorf("traffic log line %d: %w", line, err)
+		}
+
+		if rp.interval > 0 {
+			if wait := time.Until(next); wait > 0 {
+				time.Sleep(wait)
+			}
+			next = time.Now().Add(rp.interval)
+		}
+
+		hfc.logf("Replay: %s %s", rec.Event, req.Process)
+		if err := hfc.sendWithRetry(req, rec.Event, hfc.maxRetries); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, scanner.Err()
+}
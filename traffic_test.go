@@ -0,0 +1,175 @@
+package hawkflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionTrafficLog(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := &ClientMock{returnStatusCode: 500, returnBody: `{"message":"bad key api_key"}`}
+	hfc := New("api_key", OptionHTTPClient(c), OptionMaxRetries(2), OptionTrafficLog(buf))
+	_ = hfc.Start("test_process", "test_meta", "")
+	_ = hfc.End("test_process", "test_meta", "")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("%v expected, got %v", 4, len(lines))
+	}
+
+	records := make([]TrafficRecord, len(lines))
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if records[0].Seq != records[1].Seq || records[1].Seq == records[2].Seq {
+		t.Errorf("retries should share a sequence number, got %v", []uint64{records[0].Seq, records[1].Seq, records[2].Seq})
+	}
+
+	rec := records[0]
+	if rec.Event != "start" {
+		t.Errorf("%v expected, got %v", "start", rec.Event)
+	}
+	if rec.Endpoint != "https://api.hawkflow.ai/v1/start" {
+		t.Errorf("%v expected, got %v", "https://api.hawkflow.ai/v1/start", rec.Endpoint)
+	}
+	if string(rec.Body) != `{"process":"test_process","meta":"test_meta"}` {
+		t.Errorf("%v expected, got %v", `{"process":"test_process","meta":"test_meta"}`, string(rec.Body))
+	}
+	if rec.Status != 500 {
+		t.Errorf("%v expected, got %v", 500, rec.Status)
+	}
+	if rec.Headers["x-hawkflow-api-key"] != "REDACTED" {
+		t.Errorf("%v expected, got %v", "REDACTED", rec.Headers["x-hawkflow-api-key"])
+	}
+	if rec.Response != `{"message":"bad key REDACTED"}` {
+		t.Errorf("%v expected, got %v", `{"message":"bad key REDACTED"}`, rec.Response)
+	}
+	if strings.Contains(buf.String(), "api_key\"") {
+		t.Errorf("API key leaked into traffic log: %v", buf.String())
+	}
+}
+
+func TestOptionTrafficLogClientError(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := &ClientMock{clientError: io.ErrUnexpectedEOF}
+	hfc := New("api_key", OptionHTTPClient(c), OptionMaxRetries(1), OptionTrafficLog(buf))
+	_ = hfc.Start("test_process", "", "")
+
+	var rec TrafficRecord
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Error != io.ErrUnexpectedEOF.Error() {
+		t.Errorf("%v expected, got %v", io.ErrUnexpectedEOF.Error(), rec.Error)
+	}
+	if rec.Status != 0 {
+		t.Errorf("%v expected, got %v", 0, rec.Status)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	testCases := map[string]struct {
+		log           string
+		expectedCount uint8
+		expectedSent  int
+		error         string
+	}{
+		"Retries are sent once": {
+			log: `{"seq":1,"event":"start","body":{"process":"test_process"},"status":500}
+{"seq":1,"event":"start","body":{"process":"test_process"},"status":201}
+
+{"seq":2,"event":"metrics","body":{"process":"test_process","items":{"key":1}},"status":201}
+{"seq":3,"event":"end","body":{"process":"test_process"},"status":201}`,
+			expectedCount: 3,
+			expectedSent:  3,
+		},
+		"Retries are matched by idempotency key": {
+			log: `{"seq":1,"event":"start","headers":{"idempotency-key":"a"},"body":{"process":"test_process"},"status":500}
+{"seq":1,"event":"start","headers":{"idempotency-key":"a"},"body":{"process":"test_process"},"status":201}
+{"seq":1,"event":"start","headers":{"idempotency-key":"b"},"body":{"process":"test_process"},"status":201}`,
+			expectedCount: 2,
+			expectedSent:  2,
+		},
+		"Malformed line": {
+			log: `{"seq":1,"event":"start","body":{"process":"test_process"}}
+{"seq":2,`,
+			expectedCount: 1,
+			expectedSent:  1,
+			error:         "traffic log line 2: unexpected end of JSON input",
+		},
+		"Invalid event": {
+			log:           `{"seq":1,"event":"start","body":{"process":"invalid process ❌"}}`,
+			expectedCount: 0,
+			expectedSent:  0,
+			error:         "traffic log line 1: Process parameter contains unsupported characters. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := &ClientMock{returnStatusCode: 201}
+			hfc := New("api_key", OptionHTTPClient(c))
+			sent, err := hfc.Replay(strings.NewReader(testCase.log))
+			errorMsg := ""
+			if err != nil {
+				errorMsg = err.Error()
+			}
+
+			if c.count != testCase.expectedCount {
+				t.Errorf("%v expected, got %v", testCase.expectedCount, c.count)
+			}
+			if sent != testCase.expectedSent {
+				t.Errorf("%v expected, got %v", testCase.expectedSent, sent)
+			}
+			if errorMsg != testCase.error {
+				t.Errorf("%v expected, got %v", testCase.error, errorMsg)
+			}
+		})
+	}
+}
+
+func TestReplaySharedLog(t *testing.T) {
+	// Two clients, or two runs of one program, write to the same log and
+	// number their events from 1.
+	var log bytes.Buffer
+	for _, process := range []string{"first_process", "second_process"} {
+		hfc := New("api_key", OptionHTTPClient(&ClientMock{returnStatusCode: 201}), OptionTrafficLog(&log))
+		if err := hfc.Start(process, "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &ClientMock{returnStatusCode: 201}
+	sent, err := New("api_key", OptionHTTPClient(c)).Replay(&log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 2 || c.count != 2 {
+		t.Errorf("%v expected, got %v sent and %v requests", 2, sent, c.count)
+	}
+}
+
+func TestReplayRate(t *testing.T) {
+	log := `{"seq":1,"event":"start","body":{"process":"test_process"}}
+{"seq":2,"event":"end","body":{"process":"test_process"}}
+{"seq":3,"event":"start","body":{"process":"test_process"}}`
+
+	c := &ClientMock{returnStatusCode: 201}
+	hfc := New("api_key", OptionHTTPClient(c))
+	started := time.Now()
+	_, err := hfc.Replay(strings.NewReader(log), ReplayRate(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(started); elapsed < 100*time.Millisecond {
+		t.Errorf("at least 100ms expected at 20 events per second, got %v", elapsed)
+	}
+}
@@ -0,0 +1,138 @@
+package hawkflow
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"time"
+)
+
+// defaultTransport is shared by all clients without a custom HTTP client, so
+// connections to the API are reused across them.
+var defaultTransport = newTransport()
+
+// newTransport returns a transport tuned for many small requests to a single
+// host. Unlike http.DefaultTransport it keeps an idle connection per
+// concurrent caller instead of two.
+func newTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   runtime.GOMAXPROCS(0) + 1,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+}
+
+// transportConfig collects the proxy and TLS options, which are applied to a
+// copy of the client's transport once all options have run.
+type transportConfig struct {
+	proxySet      bool
+	proxy         func(*http.Request) (*url.URL, error)
+	rootCAs       []string
+	cert          *certReloader
+	minTLSVersion uint16
+
+	err error
+}
+
+func (t *transportConfig) empty() bool {
+	return !t.proxySet && len(t.rootCAs) == 0 && t.cert == nil && t.minTLSVersion == 0
+}
+
+// configureTransport applies the proxy and TLS options. Errors are kept and
+// returned by every call, as New has no error to return.
+func (hfc *client) configureTransport() {
+	if hfc.transport.err != nil {
+		hfc.configErr = hfc.transport.err
+		return
+	}
+	if hfc.transport.empty() {
+		return
+	}
+
+	c, ok := hfc.httpClient.(*http.Client)
+	if !ok {
+		hfc.configErr = createError("Proxy and TLS options require an *http.Client.")
+		return
+	}
+	base, ok := c.Transport.(*http.Transport)
+	if c.Transport == nil {
+		base, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if !ok {
+		hfc.configErr = createError("Proxy and TLS options require an *http.Transport.")
+		return
+	}
+
+	t := base.Clone()
+	if hfc.transport.proxySet {
+		t.Proxy = hfc.transport.proxy
+	}
+	tlsConfig, err := hfc.transport.tlsConfig(t.TLSClientConfig)
+	if err != nil {
+		hfc.configErr = err
+		return
+	}
+	t.TLSClientConfig = tlsConfig
+
+	copied := *c
+	copied.Transport = t
+	hfc.httpClient = &copied
+}
+
+// applyTimeout sets the timeout of OptionTimeout once all options have run,
+// so it works regardless of option order. An *http.Client is copied rather
+// than modified; any other client gets a deadline on each request instead.
+func (hfc *client) applyTimeout() {
+	if !hfc.timeoutSet {
+		return
+	}
+	if hfc.timeout < 0 {
+		hfc.timeout = 0
+	}
+
+	if c, ok := hfc.httpClient.(*http.Client); ok {
+		copied := *c
+		copied.Timeout = hfc.timeout
+		hfc.httpClient = &copied
+		return
+	}
+	hfc.requestTimeout = hfc.timeout
+}
+
+func (hfc *client) do(req *http.Request) (*http.Response, error) {
+	if hfc.requestTimeout <= 0 {
+		return hfc.httpClient.Do(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), hfc.requestTimeout)
+	resp, err := hfc.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases the request context once the response is consumed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
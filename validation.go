@@ -2,125 +2,368 @@ package hawkflow
 
 import (
 	"fmt"
-	"regexp"
+	"math"
+	"sort"
+	"strings"
 )
 
-func validateApiKey(apiKey string) error {
-	if apiKey == "" {
-		return createError("No API Key set.")
-	}
+// FieldLimit describes the rules the HawkFlow API enforces on a field.
+type FieldLimit struct {
+	// MaxLength is the maximum length in bytes, or 0 for no limit.
+	MaxLength int `json:"max_length"`
+	// Required fields must not be empty.
+	Required bool `json:"required"`
+	// Restricted fields may only contain letters, digits, whitespace, _ and -.
+	Restricted bool `json:"restricted"`
+}
 
-	if len(apiKey) > 50 {
-		return createError("Invalid API Key format.")
-	}
+// LimitSet holds the rules for every field of an event. For Items, MaxLength
+// is the maximum number of items per Metrics call.
+type LimitSet struct {
+	APIKey    FieldLimit `json:"api_key"`
+	Process   FieldLimit `json:"process"`
+	Meta      FieldLimit `json:"meta"`
+	UID       FieldLimit `json:"uid"`
+	Exception FieldLimit `json:"exception"`
+	Items     FieldLimit `json:"items"`
+	ItemKey   FieldLimit `json:"item_key"`
+}
 
-	if m, _ := regexp.MatchString("^[a-zA-Z\\d\\s_-]*$", apiKey); m == false {
-		return createError("Invalid API Key format.")
-	}
+// defaultLimits are the rules every event is validated against unless
+// OptionRemoteLimits replaces them.
+var defaultLimits = LimitSet{
+	APIKey:    FieldLimit{MaxLength: 50, Required: true, Restricted: true},
+	Process:   FieldLimit{MaxLength: 250, Required: true, Restricted: true},
+	Meta:      FieldLimit{MaxLength: 500, Restricted: true},
+	UID:       FieldLimit{MaxLength: 50, Restricted: true},
+	Exception: FieldLimit{MaxLength: 15000},
+	Items:     FieldLimit{MaxLength: 100, Required: true},
+	ItemKey:   FieldLimit{MaxLength: 50, Required: true, Restricted: true},
+}
 
-	return nil
+// DefaultLimits returns a copy of the built-in rules every event is validated
+// against before it is sent.
+func DefaultLimits() LimitSet {
+	return defaultLimits
 }
 
-func validateTimedData(r *request) error {
-	if err := validateProcess(r.Process); err != nil {
-		return err
+// allowedBytes is the [a-zA-Z\d\s_-] character class of restricted fields.
+var allowedBytes [256]bool
+
+func init() {
+	for c := 'a'; c <= 'z'; c++ {
+		allowedBytes[c] = true
 	}
-	if err := validateMeta(r.Meta); err != nil {
-		return err
+	for c := 'A'; c <= 'Z'; c++ {
+		allowedBytes[c] = true
 	}
-	if err := validateUID(r.UID); err != nil {
-		return err
+	for c := '0'; c <= '9'; c++ {
+		allowedBytes[c] = true
 	}
+	for _, c := range "_- \t\n\f\r" {
+		allowedBytes[c] = true
+	}
+}
 
-	return nil
+func isAllowedByte(c byte) bool {
+	return allowedBytes[c]
 }
 
-func validateException(r *request) error {
-	if err := validateProcess(r.Process); err != nil {
-		return err
+func isAllowed(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !allowedBytes[s[i]] {
+			return false
+		}
 	}
-	if err := validateMeta(r.Meta); err != nil {
-		return err
+	return true
+}
+
+// appendField appends the first rule value breaks, if any. missing is the
+// message for an empty required field and name prefixes the other messages.
+func appendField(vs []Violation, field, value string, limit FieldLimit, missing, name string) []Violation {
+	msg := ""
+	switch {
+	case value == "":
+		if limit.Required {
+			msg = missing
+		}
+	case limit.MaxLength > 0 && len(value) > limit.MaxLength:
+		msg = fmt.Sprintf("%s exceeded max length of %d characters.", name, limit.MaxLength)
+	case limit.Restricted && !isAllowed(value):
+		msg = name + " contains unsupported characters."
 	}
-	if err := validateExceptionMessage(r.ExceptionMessage); err != nil {
-		return err
+
+	if msg == "" {
+		return vs
 	}
+	return append(vs, Violation{Field: field, Message: msg})
+}
 
-	return nil
+func (l *LimitSet) appendProcess(vs []Violation, process string) []Violation {
+	return appendField(vs, "process", process, l.Process, "No process set.", "Process parameter")
 }
 
-func validateMetrics(r *request) error {
-	if err := validateProcess(r.Process); err != nil {
-		return err
+func (l *LimitSet) appendMeta(vs []Violation, meta string) []Violation {
+	return appendField(vs, "meta", meta, l.Meta, "No meta set.", "Meta parameter")
+}
+
+func (l *LimitSet) appendUID(vs []Violation, uid string) []Violation {
+	return appendField(vs, "uid", uid, l.UID, "No UID set.", "UID parameter")
+}
+
+func (l *LimitSet) appendException(vs []Violation, exceptionMessage string) []Violation {
+	return appendField(vs, "exception", exceptionMessage, l.Exception, "No exception message set.", "ExceptionMessage parameter")
+}
+
+func (l *LimitSet) appendItems(vs []Violation, items map[string]float64) []Violation {
+	if len(items) == 0 {
+		if l.Items.Required {
+			vs = append(vs, Violation{Field: "items", Message: "No items set."})
+		}
+		return vs
 	}
-	if err := validateMeta(r.Meta); err != nil {
-		return err
+
+	if l.Items.MaxLength > 0 && len(items) > l.Items.MaxLength {
+		vs = append(vs, Violation{Field: "items", Message: fmt.Sprintf("Items exceeded max count of %d.", l.Items.MaxLength)})
+	}
+
+	n := len(vs)
+	for k, v := range items {
+		if msg := l.checkItem(k, v); msg != "" {
+			vs = append(vs, Violation{Field: "item", Item: k, Message: msg})
+		}
 	}
-	if err := validateMetricsItems(r.Items); err != nil {
-		return err
+	if itemViolations := vs[n:]; len(itemViolations) > 1 {
+		sort.Slice(itemViolations, func(i, j int) bool { return itemViolations[i].Item < itemViolations[j].Item })
 	}
 
-	return nil
+	return vs
 }
 
-func validateProcess(process string) error {
-	if process == "" {
-		return createError("No process set.")
+// checkItem returns a message describing what is wrong with an item, or ""
+// if it is valid.
+func (l *LimitSet) checkItem(k string, v float64) string {
+	switch {
+	case k == "" && l.ItemKey.Required:
+		return "Empty item key."
+	case l.ItemKey.MaxLength > 0 && len(k) > l.ItemKey.MaxLength:
+		return fmt.Sprintf("Item key %s exceeded max length of %d characters.", k, l.ItemKey.MaxLength)
+	case l.ItemKey.Restricted && !isAllowed(k):
+		return fmt.Sprintf("Item key %s contains unsupported characters.", k)
+	case math.IsNaN(v) || math.IsInf(v, 0):
+		return fmt.Sprintf("Item %s value %v is not a finite number.", k, v)
 	}
+	return ""
+}
 
-	if len(process) > 250 {
-		return createError("Process parameter exceeded max length of 250 characters.")
+// violations returns every rule r breaks as an event sent to path.
+func (l *LimitSet) violations(path string, r *request) []Violation {
+	vs := l.appendProcess(nil, r.Process)
+	vs = l.appendMeta(vs, r.Meta)
+
+	if r.ParentUID != "" {
+		vs = appendField(vs, "parent_uid", r.ParentUID, l.UID, "", "ParentUID parameter")
 	}
 
-	if m, _ := regexp.MatchString("^[a-zA-Z\\d\\s_-]*$", process); m == false {
-		return createError("Process parameter contains unsupported characters.")
+	switch path {
+	case "start", "end":
+		vs = l.appendUID(vs, r.UID)
+	case "exception":
+		// A uid is only set on events sent through a Timer.
+		if r.UID != "" {
+			vs = l.appendUID(vs, r.UID)
+		}
+		vs = l.appendException(vs, r.ExceptionMessage)
+	case "metrics":
+		if r.UID != "" {
+			vs = l.appendUID(vs, r.UID)
+		}
+		vs = l.appendItems(vs, r.Items)
 	}
 
-	return nil
+	return vs
 }
 
-func validateMeta(meta string) error {
-	if len(meta) > 500 {
-		return createError("Meta parameter exceeded max length of 500 characters.")
+func (l *LimitSet) validate(path string, r *request, all bool) error {
+	switch path {
+	case "start", "end", "exception", "metrics":
+	default:
+		return createError(fmt.Sprintf("Unknown event %s.", path))
 	}
 
-	if m, _ := regexp.MatchString("^[a-zA-Z\\d\\s_-]*$", meta); m == false {
-		return createError("Meta parameter contains unsupported characters.")
+	return newValidationError(l.violations(path, r), all)
+}
+
+func (l *LimitSet) validateApiKey(apiKey string) error {
+	if apiKey == "" {
+		return createError("No API Key set.")
+	}
+
+	if appendField(nil, "api_key", apiKey, l.APIKey, "", "") != nil {
+		return createError("Invalid API Key format.")
 	}
 
 	return nil
 }
 
-func validateUID(uid string) error {
-	if len(uid) > 50 {
-		return createError("UID parameter exceeded max length of 50 characters.")
+// newValidationError returns nil if there are no violations. Unless all is
+// set, only the violations of the first failing field are kept.
+func newValidationError(vs []Violation, all bool) error {
+	if len(vs) == 0 {
+		return nil
 	}
 
-	if m, _ := regexp.MatchString("^[a-zA-Z\\d\\s_-]*$", uid); m == false {
-		return createError("UID parameter contains unsupported characters.")
+	if !all {
+		n := 1
+		for n < len(vs) && vs[n].Field == vs[0].Field {
+			n++
+		}
+		vs = vs[:n]
 	}
 
-	return nil
+	return &ValidationError{Violations: vs}
 }
 
-func validateExceptionMessage(exceptionMessage string) error {
-	if len(exceptionMessage) > 15000 {
-		return createError("ExceptionMessage parameter exceeded max length of 15000 characters.")
+// Fields are the user-supplied parts of an event, for validating them
+// outside of the client, e.g. process names defined in configuration.
+type Fields struct {
+	Process   string
+	Meta      string
+	UID       string
+	Exception string
+	// Items are only checked when not nil.
+	Items map[string]float64
+}
+
+// ValidateAll returns every rule f breaks, or nil if f is valid.
+func ValidateAll(f Fields) []Violation {
+	vs := defaultLimits.appendProcess(nil, f.Process)
+	vs = defaultLimits.appendMeta(vs, f.Meta)
+	vs = defaultLimits.appendUID(vs, f.UID)
+	vs = defaultLimits.appendException(vs, f.Exception)
+	if f.Items != nil {
+		vs = defaultLimits.appendItems(vs, f.Items)
 	}
 
-	return nil
+	return vs
+}
+
+func validateApiKey(apiKey string) error {
+	return defaultLimits.validateApiKey(apiKey)
+}
+
+func validateTimedData(r *request) error {
+	return defaultLimits.validate("start", r, false)
+}
+
+func validateException(r *request) error {
+	return defaultLimits.validate("exception", r, false)
+}
+
+func validateMetrics(r *request) error {
+	return defaultLimits.validate("metrics", r, false)
+}
+
+func validateEvent(path string, r *request) error {
+	return defaultLimits.validate(path, r, false)
+}
+
+func validateProcess(process string) error {
+	return newValidationError(defaultLimits.appendProcess(nil, process), false)
+}
+
+func validateMeta(meta string) error {
+	return newValidationError(defaultLimits.appendMeta(nil, meta), false)
+}
+
+func validateUID(uid string) error {
+	return newValidationError(defaultLimits.appendUID(nil, uid), false)
+}
+
+func validateExceptionMessage(exceptionMessage string) error {
+	return newValidationError(defaultLimits.appendException(nil, exceptionMessage), false)
 }
 
 func validateMetricsItems(items map[string]float64) error {
-	if len(items) == 0 {
-		return createError("No items set.")
+	return newValidationError(defaultLimits.appendItems(nil, items), false)
+}
+
+// Violation is a single rule broken by an event.
+type Violation struct {
+	// Field is process, meta, uid, exception, items or item.
+	Field string
+	// Item is the offending item key when Field is item.
+	Item    string
+	Message string
+}
+
+// ValidationError lists every violation found in an event.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Message
 	}
+	return createError(strings.Join(msgs, " ")).Error()
+}
+
+// NonFinitePolicy decides what Metrics does with NaN and ±Inf item values.
+type NonFinitePolicy uint8
 
-	for k := range items {
-		if len(k) > 50 {
-			return createError(fmt.Sprintf("Item key %s exceeded max length of 50 characters.", k))
+const (
+	// NonFiniteReject fails the whole call with a ValidationError.
+	NonFiniteReject NonFinitePolicy = iota
+	// NonFiniteDrop removes the offending items and sends the rest.
+	NonFiniteDrop
+	// NonFiniteClamp sends ±Inf as ±math.MaxFloat64 and NaN as 0.
+	NonFiniteClamp
+)
+
+func OptionNonFinite(p NonFinitePolicy) func(*client) {
+	return func(hfc *client) { hfc.nonFinite = p }
+}
+
+// repairItems applies the client's NonFinitePolicy, returning a copy of items
+// if anything had to change.
+func (hfc *client) repairItems(items map[string]float64) map[string]float64 {
+	if hfc.nonFinite == NonFiniteReject {
+		return items
+	}
+
+	var repaired map[string]float64
+	for k, v := range items {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			continue
+		}
+		if repaired == nil {
+			repaired = make(map[string]float64, len(items))
+			for k, v := range items {
+				repaired[k] = v
+			}
 		}
+
+		if hfc.nonFinite == NonFiniteDrop {
+			hfc.logf("Dropped item %s with value %v", k, v)
+			delete(repaired, k)
+			continue
+		}
+
+		switch {
+		case math.IsInf(v, 1):
+			repaired[k] = math.MaxFloat64
+		case math.IsInf(v, -1):
+			repaired[k] = -math.MaxFloat64
+		default:
+			repaired[k] = 0
+		}
+		hfc.logf("Clamped item %s with value %v to %v", k, v, repaired[k])
 	}
 
-	return nil
+	if repaired == nil {
+		return items
+	}
+	return repaired
 }
@@ -1,6 +1,11 @@
 package hawkflow
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -360,7 +365,23 @@ func TestValidateMetricsItems(t *testing.T) {
 		},
 		"Empty metrics items key": {
 			items: map[string]float64{"": 123.45},
-			error: "",
+			error: "Empty item key. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+		},
+		"Invalid metrics items key": {
+			items: map[string]float64{"rows.total": 12},
+			error: "Item key rows.total contains unsupported characters. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+		},
+		"NaN metrics items value": {
+			items: map[string]float64{"key": math.NaN()},
+			error: "Item key value NaN is not a finite number. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+		},
+		"Every offending item is listed": {
+			items: map[string]float64{"ok": 1, "b": math.Inf(1), "a": math.Inf(-1), "c.d": 3},
+			error: "Item a value -Inf is not a finite number. Item b value +Inf is not a finite number. Item key c.d contains unsupported characters. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+		},
+		"Too many metrics items": {
+			items: tooManyItems(),
+			error: "Items exceeded max count of 100. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
 		},
 		"Too long metrics items key": {
 			items: map[string]float64{"________10________20________30________40________50x": 123},
@@ -381,3 +402,195 @@ func TestValidateMetricsItems(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateEvent(t *testing.T) {
+	testCases := map[string]struct {
+		path  string
+		r     *request
+		error string
+	}{
+		"Valid start": {
+			path: "start",
+			r:    &request{Process: "process"},
+		},
+		"Valid end": {
+			path: "end",
+			r:    &request{Process: "process", UID: "uid"},
+		},
+		"Valid exception": {
+			path: "exception",
+			r:    &request{Process: "process", ExceptionMessage: "message"},
+		},
+		"Invalid metrics": {
+			path:  "metrics",
+			r:     &request{Process: "process"},
+			error: "No items set. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+		},
+		"Unknown event": {
+			path:  "unknown",
+			r:     &request{Process: "process"},
+			error: "Unknown event unknown. Please see documentation at https://docs.hawkflow.ai/integration/index.html",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateEvent(testCase.path, testCase.r)
+			errorMsg := ""
+			if err != nil {
+				errorMsg = err.Error()
+			}
+			if errorMsg != testCase.error {
+				t.Errorf("%v expected, got %v", testCase.error, errorMsg)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	testCases := map[string]struct {
+		limit     FieldLimit
+		maxLength int
+		required  bool
+	}{
+		"API key":   {limit: defaultLimits.APIKey, maxLength: 50, required: true},
+		"Process":   {limit: defaultLimits.Process, maxLength: 250, required: true},
+		"Meta":      {limit: defaultLimits.Meta, maxLength: 500},
+		"UID":       {limit: defaultLimits.UID, maxLength: 50},
+		"Exception": {limit: defaultLimits.Exception, maxLength: 15000},
+		"Items":     {limit: defaultLimits.Items, maxLength: 100, required: true},
+		"Item key":  {limit: defaultLimits.ItemKey, maxLength: 50, required: true},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if testCase.limit.MaxLength != testCase.maxLength {
+				t.Errorf("%v expected, got %v", testCase.maxLength, testCase.limit.MaxLength)
+			}
+			if testCase.limit.Required != testCase.required {
+				t.Errorf("%v expected, got %v", testCase.required, testCase.limit.Required)
+			}
+		})
+	}
+}
+
+func TestIsAllowedMatchesCharacterClass(t *testing.T) {
+	re := regexp.MustCompile(`^[a-zA-Z\d\s_-]$`)
+	for c := 0; c < 256; c++ {
+		s := string([]byte{byte(c)})
+		if isAllowed(s) != re.MatchString(s) {
+			t.Errorf("byte %#x: %v expected, got %v", c, re.MatchString(s), isAllowed(s))
+		}
+	}
+}
+
+func TestValidateDoesNotAllocate(t *testing.T) {
+	timed := &request{Process: "test_process", Meta: "test_meta", UID: "test_uid"}
+	metrics := &request{Process: "test_process", Items: map[string]float64{"rows": 12, "cols": 3}}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = validateApiKey("api_key")
+		_ = validateTimedData(timed)
+		_ = validateMetrics(metrics)
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations expected, got %v", 0, allocs)
+	}
+}
+
+func BenchmarkValidateTimedData(b *testing.B) {
+	r := &request{Process: "test_process", Meta: "test_meta", UID: "test_uid"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = validateTimedData(r)
+	}
+}
+
+func BenchmarkValidateMetrics(b *testing.B) {
+	r := &request{Process: "test_process", Items: map[string]float64{"rows": 12, "cols": 3, "size": 1024}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = validateMetrics(r)
+	}
+}
+
+func tooManyItems() map[string]float64 {
+	items := make(map[string]float64)
+	for i := 0; i <= 100; i++ {
+		items[fmt.Sprintf("key_%d", i)] = float64(i)
+	}
+	return items
+}
+
+func TestValidationErrorViolations(t *testing.T) {
+	err := validateMetricsItems(map[string]float64{"ok": 1, "b": math.NaN(), "": 2})
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ValidationError expected, got %T", err)
+	}
+
+	expected := []Violation{
+		{Field: "item", Item: "", Message: "Empty item key."},
+		{Field: "item", Item: "b", Message: "Item b value NaN is not a finite number."},
+	}
+	if len(verr.Violations) != len(expected) {
+		t.Fatalf("%v expected, got %v", expected, verr.Violations)
+	}
+	for i := range expected {
+		if verr.Violations[i] != expected[i] {
+			t.Errorf("%v expected, got %v", expected[i], verr.Violations[i])
+		}
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	testCases := map[string]struct {
+		fields   Fields
+		expected []Violation
+	}{
+		"Valid process name": {
+			fields: Fields{Process: "nightly_export"},
+		},
+		"Valid full event": {
+			fields: Fields{Process: "process", Meta: "meta", UID: "uid", Exception: "message", Items: map[string]float64{"key": 1}},
+		},
+		"Every field is reported": {
+			fields: Fields{
+				Process: "invalid process ❌",
+				Meta:    strings.Repeat("m", 501),
+				UID:     "invalid uid ❌",
+				Items:   map[string]float64{"a.b": 1, "c": math.NaN(), "ok": 2},
+			},
+			expected: []Violation{
+				{Field: "process", Message: "Process parameter contains unsupported characters."},
+				{Field: "meta", Message: "Meta parameter exceeded max length of 500 characters."},
+				{Field: "uid", Message: "UID parameter contains unsupported characters."},
+				{Field: "item", Item: "a.b", Message: "Item key a.b contains unsupported characters."},
+				{Field: "item", Item: "c", Message: "Item c value NaN is not a finite number."},
+			},
+		},
+		"Missing process and empty items": {
+			fields: Fields{Items: map[string]float64{}},
+			expected: []Violation{
+				{Field: "process", Message: "No process set."},
+				{Field: "items", Message: "No items set."},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			violations := ValidateAll(testCase.fields)
+
+			if len(violations) != len(testCase.expected) {
+				t.Fatalf("%v expected, got %v", testCase.expected, violations)
+			}
+			for i := range testCase.expected {
+				if violations[i] != testCase.expected[i] {
+					t.Errorf("%v expected, got %v", testCase.expected[i], violations[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package hawkflow
+
+// Version is the version of this SDK. It must match the module's release tag.
+const Version = "1.1.0"
+
+// _SCHEMA_VERSION is the version of the limits document this SDK understands.
+const _SCHEMA_VERSION = 1